@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/audit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/metrics"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/parser"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type APIKeyRequest struct {
+	NAME   string   `json:"name"`
+	ROLE   string   `json:"role"`
+	SCOPES []string `json:"scopes"`
+}
+
+type APIKeyResponse struct {
+	auth.APIKey
+	KEY string `json:"key"`
+}
+
+var keyStore *auth.KeyStore
+
+// Only one import at a time, a second one would only produce duplicate errors
+var importRunning atomic.Bool
+
+// CSV file imported on startup and by the import endpoint
+var importCSVPath string
+
+func getAPIKeys(c *gin.Context) {
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	keys, err := keyStore.List(ctx)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query API keys", err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, keys)
+}
+
+func postAPIKey(c *gin.Context) {
+	var request APIKeyRequest
+	if err := c.BindJSON(&request); err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data correct?", err)
+		return
+	}
+	request.NAME = strings.TrimSpace(request.NAME)
+	if request.NAME == "" {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data complete?", nil)
+		return
+	}
+	if !auth.ValidRole(request.ROLE) {
+		respondError(c, http.StatusBadRequest, "Wrong role "+request.ROLE+", use reader, steward or admin", nil)
+		return
+	}
+	for _, scope := range request.SCOPES {
+		if !auth.ValidScope(scope) {
+			respondError(c, http.StatusBadRequest, "Wrong scope "+scope+", use read, write, delete, import or admin", nil)
+			return
+		}
+	}
+
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	var response APIKeyResponse
+	err := withTx(ctx, func(tx *sql.Tx) (err error) {
+		response.APIKey, response.KEY, err = auth.CreateKey(ctx, tx, request.NAME, request.ROLE, request.SCOPES, actorOf(c))
+		if err != nil {
+			return err
+		}
+		return auditAPIKey(ctx, tx, audit.ActionCreate, actorOf(c), nil, &response.APIKey)
+	})
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to create API key "+request.NAME, err)
+		return
+	}
+
+	// Key is only shown once, database keeps its hash
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+func deleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Wrong API key id "+c.Param("id"), nil)
+		return
+	}
+
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		key, err := auth.RevokeKey(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		before := key
+		before.REVOKED_AT = nil
+		return auditAPIKey(ctx, tx, audit.ActionDelete, actorOf(c), &before, &key)
+	})
+	if err == sql.ErrNoRows {
+		respondError(c, http.StatusNotFound, "API key "+c.Param("id")+" not found", nil)
+		return
+	}
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to revoke API key "+c.Param("id"), err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully revoked API key!"})
+}
+
+// Run the CSV import again, rows already in database are skipped
+func postImport(c *gin.Context) {
+	if !importRunning.CompareAndSwap(false, true) {
+		respondError(c, http.StatusConflict, "Import is already running", nil)
+		return
+	}
+	defer importRunning.Store(false)
+
+	// Import keeps going if the client disconnects, it only stops after bulkTimeout or on shutdown
+	ctx, cancel := bulkContext()
+	defer cancel()
+
+	counts, err := runImport(ctx)
+	if err != nil {
+		if lifecycle.Err() != nil {
+			respondError(c, http.StatusServiceUnavailable, "Import was stopped, server is shutting down", err)
+			return
+		}
+		if storageFailure(c, err) {
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Failed to import SWIFT codes", err)
+		return
+	}
+	if err := loadSearchIndexes(ctx); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to rebuild search indexes", "error", err)
+	}
+	invalidateAll()
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully imported SWIFT codes!", "rows": counts})
+}
+
+// Import the CSV file and record its outcome in metrics and one span.
+// Row inserts are left out of the trace, an import has thousands of them. Its log lines keep the span
+func runImport(ctx context.Context) (parser.Counts, error) {
+	ctx, span := tracing.Start(ctx, "import")
+	counts, err := parser.Parse(tracing.Untraced(ctx), db, importCSVPath)
+	span.SetAttributes(attribute.Int("import.inserted", counts.INSERTED),
+		attribute.Int("import.duplicates", counts.DUPLICATES), attribute.Int("import.rejected", counts.REJECTED))
+	tracing.End(span, err)
+
+	result := metrics.ImportSucceeded
+	if err != nil {
+		result = metrics.ImportFailed
+	}
+	serverMetrics.ObserveImport(result, counts.INSERTED, counts.DUPLICATES, counts.REJECTED)
+	return counts, err
+}
+
+func auditAPIKey(ctx context.Context, ex execer, action, actor string, before, after *auth.APIKey) error {
+	return audit.Record(ctx, ex, audit.Entry{
+		ENTITY: audit.EntityAPIKey,
+		KEY:    strconv.FormatInt(after.ID, 10),
+		ACTION: action,
+		ACTOR:  actor,
+		SOURCE: audit.SourceAPI,
+		BEFORE: audit.Snapshot(before),
+		AFTER:  audit.Snapshot(after),
+	})
+}
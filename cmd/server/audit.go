@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/audit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actor used when a request is not authenticated
+const anonymousActor = "anonymous"
+
+type AuditResponse struct {
+	TOTAL     int           `json:"total"`
+	PAGE      int           `json:"page"`
+	PAGE_SIZE int           `json:"pageSize"`
+	ENTRIES   []audit.Entry `json:"entries"`
+}
+
+func getAudit(c *gin.Context) {
+	page, pageSize, ok := parsePagination(c)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "Wrong pagination, page and pageSize must be positive numbers", nil)
+		return
+	}
+
+	filter := audit.Filter{KEY: strings.ToUpper(c.Query("swiftCode")), ACTOR: c.Query("actor")}
+	var err error
+	if filter.FROM, err = parseTime(c.Query("from")); err != nil {
+		respondError(c, http.StatusBadRequest, "Wrong from time "+c.Query("from")+", use RFC 3339 or YYYY-MM-DD", nil)
+		return
+	}
+	if filter.TO, err = parseTime(c.Query("to")); err != nil {
+		respondError(c, http.StatusBadRequest, "Wrong to time "+c.Query("to")+", use RFC 3339 or YYYY-MM-DD", nil)
+		return
+	}
+
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	entries, total, err := audit.Query(ctx, db, filter, (page-1)*pageSize, pageSize)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query audit log", err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, AuditResponse{total, page, pageSize, entries})
+}
+
+// Who made the change, the authenticated principal
+func actorOf(c *gin.Context) string {
+	if principal, ok := auth.PrincipalOf(c); ok {
+		return principal.NAME
+	}
+	return anonymousActor
+}
+
+// Accepts RFC 3339 times and plain dates, empty string is the zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
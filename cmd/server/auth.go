@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/rsa"
+	"os"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+)
+
+// Build authenticator from API keys and JWT verification keys in configuration,
+// keys created through the admin endpoint are checked in keyStore
+func loadAuthenticator(conf config.Auth) (*auth.Authenticator, error) {
+	apiKeys, err := auth.ParseAPIKeys(conf.API_KEYS)
+	if err != nil {
+		return nil, err
+	}
+
+	var rsaKey *rsa.PublicKey
+	if path := conf.JWT_RS256_PUBLIC_KEY_FILE; path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if rsaKey, err = auth.ParseRSAPublicKey(pem); err != nil {
+			return nil, err
+		}
+	}
+
+	authenticator := auth.New(apiKeys, []byte(conf.JWT_HS256_SECRET), rsaKey, keyStore)
+	authenticator.ExpectClaims(conf.JWT_ISSUER, conf.JWT_AUDIENCE)
+	return authenticator, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Bank struct {
+	BANK_CODE      string        `json:"bankCode"`
+	NAMES          []string      `json:"bankNames"`
+	COUNTRIES      []string      `json:"countries"`
+	HEADQUARTERS   []Headquarter `json:"headquarters"`
+	LOOSE_BRANCHES []Branch      `json:"branchesWithoutHeadquarter,omitempty"`
+}
+
+type BankSummary struct {
+	BANK_CODE         string   `json:"bankCode"`
+	NAME              string   `json:"bankName"`
+	COUNTRIES         []string `json:"countries"`
+	HEADQUARTER_COUNT int      `json:"headquarterCount"`
+	BRANCH_COUNT      int      `json:"branchCount"`
+}
+
+type BanksResponse struct {
+	TOTAL     int           `json:"total"`
+	PAGE      int           `json:"page"`
+	PAGE_SIZE int           `json:"pageSize"`
+	BANKS     []BankSummary `json:"banks"`
+}
+
+func getBanks(c *gin.Context) {
+	page, pageSize, ok := parsePagination(c)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "Wrong pagination, page and pageSize must be positive numbers", nil)
+		return
+	}
+
+	where := `WHERE deleted_at IS NULL`
+	var args []any
+	if country := strings.ToUpper(c.Query("country")); country != "" {
+		where += ` AND country_iso2 = ?`
+		args = append(args, country)
+	}
+
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT LEFT(swift_code, 4)) FROM branches `+where, args...).Scan(&total); err != nil {
+		if storageFailure(c, err) {
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Failed to query banks", err)
+		return
+	}
+
+	// Institution is identified by the first 4 characters of its SWIFT codes
+	rows, err := db.QueryContext(ctx, `
+	SELECT LEFT(swift_code, 4) AS bank_code, MIN(name), GROUP_CONCAT(DISTINCT country_iso2 ORDER BY country_iso2),
+		SUM(is_headquarter), COUNT(*) - SUM(is_headquarter)
+	FROM branches `+where+`
+	GROUP BY bank_code
+	ORDER BY bank_code
+	LIMIT ? OFFSET ?`, append(args, pageSize, (page-1)*pageSize)...)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query banks", err)
+		return
+	}
+	defer rows.Close()
+
+	banks := []BankSummary{}
+	for rows.Next() {
+		var bank BankSummary
+		var countries string
+		if err := rows.Scan(&bank.BANK_CODE, &bank.NAME, &countries, &bank.HEADQUARTER_COUNT, &bank.BRANCH_COUNT); err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
+			return
+		}
+		bank.COUNTRIES = strings.Split(countries, ",")
+		banks = append(banks, bank)
+	}
+	if err := rows.Err(); storageFailure(c, err) {
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to read banks", err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, BanksResponse{total, page, pageSize, banks})
+}
+
+func getBank(c *gin.Context) {
+	bankCode := strings.ToUpper(c.Param("bankCode"))
+	if !isBankCode(bankCode) {
+		respondError(c, http.StatusBadRequest, "Wrong bank code "+bankCode+", must be 4 letters or digits", nil)
+		return
+	}
+	lang, ok := requestLanguage(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	// Query every code of the institution, headquarters sort before their branches
+	rows, err := db.QueryContext(ctx, `
+	SELECT address, name, branches.country_iso2, country_name, swift_code, is_headquarter
+	FROM branches
+	INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+	WHERE swift_code LIKE ? AND deleted_at IS NULL
+	ORDER BY LEFT(swift_code, 8), is_headquarter DESC, swift_code`, bankCode+"%")
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query bank "+bankCode, err)
+		return
+	}
+	defer rows.Close()
+
+	var branches []Branch
+	for rows.Next() {
+		var branch Branch
+		if err := rows.Scan(&branch.ADDRESS, &branch.NAME, &branch.COUNTRY_ISO2_CODEID,
+			&branch.COUNTRY_NAME, &branch.SWIFT_CODE, &branch.IS_HEADQUARTER); err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
+			return
+		}
+		branch.COUNTRY_DETAILS = countryDetails(branch.COUNTRY_ISO2_CODEID, lang)
+		branches = append(branches, branch)
+	}
+	if err := rows.Err(); storageFailure(c, err) {
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to read bank "+bankCode, err)
+		return
+	}
+	if len(branches) == 0 {
+		respondError(c, http.StatusNotFound, "Bank "+bankCode+" not found", nil)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, groupBank(bankCode, branches))
+}
+
+// Group an institution's codes under their headquarters, branch belongs to the headquarter sharing its first 8 characters
+func groupBank(bankCode string, branches []Branch) Bank {
+	bank := Bank{BANK_CODE: bankCode, NAMES: []string{}, COUNTRIES: []string{}, HEADQUARTERS: []Headquarter{}}
+	headquarters := map[string]int{}
+	seenNames := map[string]bool{}
+	seenCountries := map[string]bool{}
+
+	for _, branch := range branches {
+		if !seenNames[branch.NAME] {
+			seenNames[branch.NAME] = true
+			bank.NAMES = append(bank.NAMES, branch.NAME)
+		}
+		if !seenCountries[branch.COUNTRY_ISO2_CODEID] {
+			seenCountries[branch.COUNTRY_ISO2_CODEID] = true
+			bank.COUNTRIES = append(bank.COUNTRIES, branch.COUNTRY_ISO2_CODEID)
+		}
+
+		prefix := branch.SWIFT_CODE
+		if len(prefix) > 8 {
+			prefix = prefix[:8]
+		}
+		if branch.IS_HEADQUARTER {
+			headquarters[prefix] = len(bank.HEADQUARTERS)
+			bank.HEADQUARTERS = append(bank.HEADQUARTERS, Headquarter{branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID,
+				branch.COUNTRY_NAME, branch.COUNTRY_DETAILS, branch.IS_HEADQUARTER, branch.SWIFT_CODE, nil, []Branch{}})
+			continue
+		}
+		if i, ok := headquarters[prefix]; ok {
+			bank.HEADQUARTERS[i].BRANCHES = append(bank.HEADQUARTERS[i].BRANCHES, branch)
+		} else {
+			bank.LOOSE_BRANCHES = append(bank.LOOSE_BRANCHES, branch)
+		}
+	}
+	return bank
+}
+
+// ISO 9362 allows letters and digits in the institution code
+func isBankCode(code string) bool {
+	if len(code) != 4 {
+		return false
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/logging"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/search"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxBatchOperations = 1000
+
+const (
+	batchAtomic     = "atomic"
+	batchBestEffort = "bestEffort"
+
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+)
+
+type BatchOperation struct {
+	OP         string  `json:"op"`
+	SWIFT_CODE string  `json:"swiftCode"`
+	BRANCH     *Branch `json:"branch,omitempty"`
+	IF_MATCH   string  `json:"ifMatch,omitempty"`
+	version    int64   // expected row version parsed from IF_MATCH
+}
+
+type BatchRequest struct {
+	MODE       string           `json:"mode"`
+	OPERATIONS []BatchOperation `json:"operations"`
+}
+
+type BatchResult struct {
+	INDEX      int    `json:"index"`
+	OP         string `json:"op"`
+	SWIFT_CODE string `json:"swiftCode"`
+	STATUS     int    `json:"status"`
+	MESSAGE    string `json:"message"`
+}
+
+type BatchResponse struct {
+	MODE      string        `json:"mode"`
+	SUCCEEDED int           `json:"succeeded"`
+	FAILED    int           `json:"failed"`
+	RESULTS   []BatchResult `json:"results"`
+	// Batches can fail partly, so the request id is always sent
+	REQUEST_ID string `json:"requestId,omitempty"`
+}
+
+func batchBranches(c *gin.Context) {
+	var request BatchRequest
+	if err := c.BindJSON(&request); err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data correct?", err)
+		return
+	}
+	if request.MODE == "" {
+		request.MODE = batchAtomic
+	}
+	if request.MODE != batchAtomic && request.MODE != batchBestEffort {
+		respondError(c, http.StatusBadRequest, "Wrong batch mode "+request.MODE+", use atomic or bestEffort", nil)
+		return
+	}
+	if len(request.OPERATIONS) == 0 || len(request.OPERATIONS) > maxBatchOperations {
+		respondError(c, http.StatusBadRequest, "Batch needs between 1 and "+strconv.Itoa(maxBatchOperations)+" operations", nil)
+		return
+	}
+
+	// Route needs write scope, deleting needs delete scope on top
+	for _, op := range request.OPERATIONS {
+		if strings.ToLower(op.OP) == batchDelete && !auth.HasScope(c, auth.ScopeDelete) {
+			respondError(c, http.StatusForbidden, "Missing delete scope for delete operations", nil)
+			return
+		}
+	}
+
+	// Whole batch shares one write timeout
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	response := BatchResponse{MODE: request.MODE, RESULTS: make([]BatchResult, len(request.OPERATIONS)),
+		REQUEST_ID: logging.RequestID(c.Request.Context())}
+	valid := true
+	for i := range request.OPERATIONS {
+		op := &request.OPERATIONS[i]
+		status, message := prepareOperation(op)
+		response.RESULTS[i] = BatchResult{i, op.OP, op.SWIFT_CODE, status, message}
+		valid = valid && status == http.StatusOK
+	}
+
+	actor := actorOf(c)
+	if request.MODE == batchAtomic {
+		if valid {
+			valid = applyAtomic(ctx, request.OPERATIONS, response.RESULTS, actor)
+		}
+		if !valid {
+			markRolledBack(response.RESULTS)
+		}
+	} else {
+		for i, op := range request.OPERATIONS {
+			if response.RESULTS[i].STATUS != http.StatusOK {
+				continue
+			}
+			result := &response.RESULTS[i]
+			err := withTx(ctx, func(tx *sql.Tx) error {
+				result.STATUS, result.MESSAGE = applyOperation(ctx, tx, op, actor)
+				if result.STATUS != http.StatusOK {
+					return errors.New(result.MESSAGE)
+				}
+				return nil
+			})
+			if err != nil && result.STATUS == http.StatusOK {
+				slog.ErrorContext(ctx, "Failed to commit transaction", "swiftCode", op.SWIFT_CODE, "error", err)
+				result.STATUS, result.MESSAGE = transactionFailure(err, "Failed to commit transaction")
+			}
+			if result.STATUS == http.StatusOK {
+				indexOperation(ctx, op)
+			}
+		}
+	}
+
+	for _, result := range response.RESULTS {
+		if result.STATUS == http.StatusOK {
+			response.SUCCEEDED++
+		} else {
+			response.FAILED++
+		}
+	}
+
+	if request.MODE == batchAtomic && !valid {
+		c.IndentedJSON(atomicFailureStatus(response.RESULTS), response)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// Run all operations in one transaction, results are filled in up to the first failure
+func applyAtomic(ctx context.Context, operations []BatchOperation, results []BatchResult, actor string) bool {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to start transaction", "error", err)
+		for i := range results {
+			results[i].STATUS, results[i].MESSAGE = transactionFailure(err, "Failed to start transaction")
+		}
+		return false
+	}
+
+	for i, op := range operations {
+		results[i].STATUS, results[i].MESSAGE = applyOperation(ctx, tx, op, actor)
+		if results[i].STATUS != http.StatusOK {
+			if err := tx.Rollback(); err != nil {
+				slog.ErrorContext(ctx, "Failed to roll back transaction", "error", err)
+			}
+			return false
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(ctx, "Failed to commit transaction", "error", err)
+		for i := range results {
+			results[i].STATUS, results[i].MESSAGE = transactionFailure(err, "Failed to commit transaction")
+		}
+		return false
+	}
+
+	for _, op := range operations {
+		indexOperation(ctx, op)
+	}
+	return true
+}
+
+// Status and message of a transaction that could not start or commit
+func transactionFailure(err error, message string) (int, string) {
+	if status, storageMessage, ok := storageStatus(err); ok {
+		return status, storageMessage
+	}
+	return http.StatusBadRequest, message
+}
+
+// Failed atomic batch is answered with the timeout or unavailable status of its failed operation, if it has one
+func atomicFailureStatus(results []BatchResult) int {
+	for _, result := range results {
+		if result.STATUS == http.StatusGatewayTimeout || result.STATUS == http.StatusServiceUnavailable {
+			return result.STATUS
+		}
+	}
+	return http.StatusBadRequest
+}
+
+// Successful results of a failed atomic batch were never committed
+func markRolledBack(results []BatchResult) {
+	for i := range results {
+		if results[i].STATUS == http.StatusOK {
+			results[i].STATUS = http.StatusFailedDependency
+			results[i].MESSAGE = "Not applied, another operation in the batch failed"
+		}
+	}
+}
+
+// Normalize and check an operation before anything is read or written, the branch country is checked
+// when the operation is applied
+func prepareOperation(op *BatchOperation) (int, string) {
+	op.OP = strings.ToLower(op.OP)
+	op.SWIFT_CODE = strings.TrimSpace(op.SWIFT_CODE)
+
+	switch op.OP {
+	case batchCreate, batchUpdate:
+		if op.BRANCH == nil {
+			return http.StatusBadRequest, "Missing branch for " + op.OP + " operation"
+		}
+		if op.SWIFT_CODE == "" {
+			op.SWIFT_CODE = op.BRANCH.SWIFT_CODE
+		}
+		if op.BRANCH.SWIFT_CODE == "" {
+			op.BRANCH.SWIFT_CODE = op.SWIFT_CODE
+		}
+		if op.BRANCH.SWIFT_CODE != op.SWIFT_CODE {
+			return http.StatusBadRequest, "Swift code " + op.SWIFT_CODE + " does not match branch " + op.BRANCH.SWIFT_CODE
+		}
+		if status, message := checkBranch(op.BRANCH, branchAction(op.OP)); status != http.StatusOK {
+			return status, message
+		}
+	case batchDelete:
+		if op.SWIFT_CODE == "" {
+			return http.StatusBadRequest, "Missing swift code for delete operation"
+		}
+	default:
+		return http.StatusBadRequest, "Wrong operation " + op.OP + ", use create, update or delete"
+	}
+
+	// Updates and deletes work like requests with an If-Match header
+	if op.OP != batchCreate {
+		var status int
+		var message string
+		if op.version, status, message = checkIfMatch(op.IF_MATCH, op.SWIFT_CODE); status != http.StatusOK {
+			return status, message
+		}
+	}
+	return http.StatusOK, ""
+}
+
+// Verb of an operation in validation messages
+func branchAction(op string) string {
+	if op == batchUpdate {
+		return "update"
+	}
+	return "insert"
+}
+
+func applyOperation(ctx context.Context, ex execer, op BatchOperation, actor string) (int, string) {
+	// Country is checked and registered in the batch transaction, so it can't change before the branch
+	// is written and an atomic batch that fails leaves no country behind
+	if op.OP != batchDelete {
+		status, message, register := validateCountry(ctx, ex, op.BRANCH, branchAction(op.OP))
+		if status != http.StatusOK {
+			return status, message
+		}
+		if register {
+			if err := registerCountry(ctx, ex, op.BRANCH.COUNTRY_ISO2_CODEID, actor); err != nil {
+				slog.WarnContext(ctx, "Failed to register country", "countryISO2", op.BRANCH.COUNTRY_ISO2_CODEID, "error", err)
+				if status, message, ok := storageStatus(err); ok {
+					return status, message
+				}
+				return http.StatusBadRequest, "Failed to register country " + op.BRANCH.COUNTRY_ISO2_CODEID
+			}
+		}
+	}
+	switch op.OP {
+	case batchCreate:
+		if err := insertBranch(ctx, ex, *op.BRANCH, actor); err != nil {
+			slog.WarnContext(ctx, "Failed to insert branch", "swiftCode", op.SWIFT_CODE, "error", err)
+			if status, message, ok := storageStatus(err); ok {
+				return status, message
+			}
+			return http.StatusBadRequest, "Failed to insert branch: Already exists or wrong data " + op.SWIFT_CODE
+		}
+		return http.StatusOK, "Succesfully added branch to database!"
+	case batchUpdate:
+		found, err := updateBranch(ctx, ex, *op.BRANCH, op.version, actor)
+		if err == errVersionMismatch {
+			return http.StatusPreconditionFailed, versionMismatchMessage(op.SWIFT_CODE)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to update branch", "swiftCode", op.SWIFT_CODE, "error", err)
+			if status, message, ok := storageStatus(err); ok {
+				return status, message
+			}
+			return http.StatusBadRequest, "Failed to update branch: Wrong data " + op.SWIFT_CODE
+		}
+		if !found {
+			return http.StatusNotFound, "Branch " + op.SWIFT_CODE + " not found"
+		}
+		return http.StatusOK, "Succesfully updated branch in database!"
+	default:
+		found, err := removeBranch(ctx, ex, op.SWIFT_CODE, op.version, actor)
+		if err == errVersionMismatch {
+			return http.StatusPreconditionFailed, versionMismatchMessage(op.SWIFT_CODE)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to delete branch", "swiftCode", op.SWIFT_CODE, "error", err)
+			if status, message, ok := storageStatus(err); ok {
+				return status, message
+			}
+			return http.StatusBadRequest, "Failed to delete swift " + op.SWIFT_CODE + " from database"
+		}
+		if !found {
+			return http.StatusNotFound, "Branch " + op.SWIFT_CODE + " not found"
+		}
+		return http.StatusOK, "Succesfully deleted branch from database!"
+	}
+}
+
+// Keep search indexes and response cache in sync after an operation was written
+func indexOperation(ctx context.Context, op BatchOperation) {
+	invalidateBranch(op.SWIFT_CODE)
+	switch op.OP {
+	case batchCreate:
+		indexBranch(branchDocument(*op.BRANCH))
+	case batchUpdate:
+		// Stored branch keeps fields the API does not expose, like town name
+		doc, err := search.LoadDocument(ctx, db, op.SWIFT_CODE)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load updated branch for search", "swiftCode", op.SWIFT_CODE, "error", err)
+			doc = branchDocument(*op.BRANCH)
+		}
+		indexBranch(doc)
+	case batchDelete:
+		unindexBranch(op.SWIFT_CODE)
+	}
+}
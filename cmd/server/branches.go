@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/audit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/history"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/search"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Both *sql.DB and *sql.Tx, so writes can run standalone or as part of a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Helper function to check a submitted branch before anything is read or written, sets the headquarter flag
+// from the code like the importer does. Returns status and message for the client when branch can't be written,
+// action is used in that message
+func checkBranch(branch *Branch, action string) (int, string) {
+	if branch.ADDRESS == "" || branch.COUNTRY_ISO2_CODEID == "" || branch.COUNTRY_NAME == "" || branch.NAME == "" || branch.SWIFT_CODE == "" {
+		return http.StatusBadRequest, "Failed to bind JSON, is data complete?"
+	}
+	if !isSwiftCode(branch.SWIFT_CODE) {
+		return http.StatusBadRequest, "Failed to " + action + " branch: Wrong swift code " + branch.SWIFT_CODE
+	}
+	branch.IS_HEADQUARTER = strings.HasSuffix(branch.SWIFT_CODE, "XXX")
+	branch.COUNTRY_ISO2_CODEID = strings.ToUpper(branch.COUNTRY_ISO2_CODEID)
+	return http.StatusOK, ""
+}
+
+// Helper function to check the country of a checked branch in the transaction writing it, so the country can't
+// change in between, and fill in the stored country name. register is true when the country has to be registered
+// in that transaction, see registerCountry.
+func validateCountry(ctx context.Context, ex execer, branch *Branch, action string) (status int, message string, register bool) {
+	// Check if bank is in a known country and the submitted name matches the stored one
+	country, err := queryCountry(ctx, ex, branch.COUNTRY_ISO2_CODEID)
+	if err == sql.ErrNoRows && autoRegisterCountries {
+		if iso, ok := registrableCountry(branch.COUNTRY_ISO2_CODEID, branch.COUNTRY_NAME); ok {
+			branch.COUNTRY_NAME = iso.UpperName()
+			return http.StatusOK, "", true
+		}
+	}
+	if err == sql.ErrNoRows {
+		return http.StatusBadRequest, "Failed to " + action + " branch: Unknown country code " + branch.COUNTRY_ISO2_CODEID, false
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to query country", "countryISO2", branch.COUNTRY_ISO2_CODEID, "error", err)
+		if status, message, ok := storageStatus(err); ok {
+			return status, message, false
+		}
+		return http.StatusBadRequest, "Failed to query country " + branch.COUNTRY_ISO2_CODEID, false
+	}
+	if !countryNameMatches(country, branch.COUNTRY_NAME) {
+		return http.StatusBadRequest, "Failed to " + action + " branch: Country name " + branch.COUNTRY_NAME +
+			" does not match " + country.COUNTRY_NAME + " for " + country.COUNTRY_ISO2_CODEID, false
+	}
+	branch.COUNTRY_NAME = country.COUNTRY_NAME
+	return http.StatusOK, "", false
+}
+
+// Helper function to insert a branch into the database, replaces a soft deleted branch with the same code
+func insertBranch(ctx context.Context, ex execer, branch Branch, actor string) error {
+	// A soft deleted row of the code is replaced in place so its row version keeps growing
+	res, err := ex.ExecContext(ctx, `UPDATE branches SET address = ?, name = ?, country_iso2 = ?, is_headquarter = ?, town_name = NULL, time_zone = NULL,
+	deleted_at = NULL, row_version = row_version + 1 WHERE swift_code = ? AND deleted_at IS NOT NULL`,
+		branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID, branch.IS_HEADQUARTER, branch.SWIFT_CODE)
+	if err != nil {
+		return err
+	}
+	replaced, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if replaced == 0 {
+		query := `INSERT INTO branches (address, name, country_iso2, is_headquarter, swift_code) VALUES (?, ?, ?, ?, ?)`
+		if _, err := ex.ExecContext(ctx, query, branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID, branch.IS_HEADQUARTER, branch.SWIFT_CODE); err != nil {
+			return err
+		}
+	}
+	if err := newVersion(ctx, ex, branch.SWIFT_CODE); err != nil {
+		return err
+	}
+	if err := touchCountries(ctx, ex, branch.COUNTRY_ISO2_CODEID); err != nil {
+		return err
+	}
+	return auditBranch(ctx, ex, audit.ActionCreate, actor, branch.SWIFT_CODE, nil, &branch)
+}
+
+// Helper function to replace a stored branch, returns false if there is no branch with its code
+// and errVersionMismatch if version is set and not the stored one
+func updateBranch(ctx context.Context, ex execer, branch Branch, version int64, actor string) (bool, error) {
+	// MySQL reports 0 affected rows when nothing changed, so check existence first
+	before, err := loadBranch(ctx, ex, branch.SWIFT_CODE)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	query, args := matchVersion(`UPDATE branches SET address = ?, name = ?, country_iso2 = ?, is_headquarter = ?, row_version = row_version + 1
+	WHERE swift_code = ? AND deleted_at IS NULL`, []any{branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID, branch.IS_HEADQUARTER, branch.SWIFT_CODE}, version)
+	res, err := ex.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	// Row version always changes, so no affected row means another version is stored
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if version != 0 && rowsAffected == 0 {
+		return false, errVersionMismatch
+	}
+	if err := newVersion(ctx, ex, branch.SWIFT_CODE); err != nil {
+		return false, err
+	}
+	// Branch can move to another country, both countries changed then
+	if err := touchCountries(ctx, ex, before.COUNTRY_ISO2_CODEID, branch.COUNTRY_ISO2_CODEID); err != nil {
+		return false, err
+	}
+	return true, auditBranch(ctx, ex, audit.ActionUpdate, actor, branch.SWIFT_CODE, &before, &branch)
+}
+
+// Helper function to soft delete a branch, returns false if there was nothing to delete
+// and errVersionMismatch if version is set and not the stored one
+func removeBranch(ctx context.Context, ex execer, swift string, version int64, actor string) (bool, error) {
+	before, err := loadBranch(ctx, ex, swift)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	query, args := matchVersion(`UPDATE branches SET deleted_at = UTC_TIMESTAMP(), row_version = row_version + 1
+	WHERE swift_code = ? AND deleted_at IS NULL`, []any{swift}, version)
+	res, err := ex.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		if err == nil && version != 0 {
+			err = errVersionMismatch
+		}
+		return false, err
+	}
+	if err := history.Close(ctx, ex, swift, time.Now()); err != nil {
+		return false, err
+	}
+	if err := touchCountries(ctx, ex, before.COUNTRY_ISO2_CODEID); err != nil {
+		return false, err
+	}
+	return true, auditBranch(ctx, ex, audit.ActionDelete, actor, swift, &before, nil)
+}
+
+// Helper function to bring back a soft deleted branch, returns false if there was nothing to restore
+// and errVersionMismatch if version is set and not the stored one
+func restoreBranch(ctx context.Context, ex execer, swift string, version int64, actor string) (bool, error) {
+	query, args := matchVersion(`UPDATE branches SET deleted_at = NULL, row_version = row_version + 1
+	WHERE swift_code = ? AND deleted_at IS NOT NULL`, []any{swift}, version)
+	res, err := ex.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected, err := res.RowsAffected(); err != nil || rowsAffected == 0 {
+		if err == nil && version != 0 {
+			return false, deletedVersionMismatch(ctx, ex, swift)
+		}
+		return false, err
+	}
+	if err := history.Open(ctx, ex, swift, time.Now()); err != nil {
+		return false, err
+	}
+
+	after, err := loadBranch(ctx, ex, swift)
+	if err != nil {
+		return false, err
+	}
+	if err := touchCountries(ctx, ex, after.COUNTRY_ISO2_CODEID); err != nil {
+		return false, err
+	}
+	return true, auditBranch(ctx, ex, audit.ActionRestore, actor, swift, nil, &after)
+}
+
+// Tell a missing deleted branch from one with another row version, nil if there is no deleted branch
+func deletedVersionMismatch(ctx context.Context, ex execer, swift string) error {
+	var deleted int
+	if err := ex.QueryRowContext(ctx, `SELECT COUNT(*) FROM branches WHERE swift_code = ? AND deleted_at IS NOT NULL`, swift).Scan(&deleted); err != nil {
+		return err
+	}
+	if deleted > 0 {
+		return errVersionMismatch
+	}
+	return nil
+}
+
+// Helper function to read a stored branch that is not deleted, returns sql.ErrNoRows if there is none
+func loadBranch(ctx context.Context, ex execer, swift string) (Branch, error) {
+	branch := Branch{SWIFT_CODE: swift}
+	err := ex.QueryRowContext(ctx, `
+	SELECT address, name, branches.country_iso2, country_name, is_headquarter
+	FROM branches
+	INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+	WHERE swift_code = ? AND deleted_at IS NULL`, swift).Scan(&branch.ADDRESS, &branch.NAME,
+		&branch.COUNTRY_ISO2_CODEID, &branch.COUNTRY_NAME, &branch.IS_HEADQUARTER)
+	return branch, err
+}
+
+// Close the open version of a branch and start one with its stored values
+func newVersion(ctx context.Context, ex execer, swift string) error {
+	now := time.Now()
+	if err := history.Close(ctx, ex, swift, now); err != nil {
+		return err
+	}
+	return history.Open(ctx, ex, swift, now)
+}
+
+// Bump data versions of the countries a branch write changed, each country once
+func touchCountries(ctx context.Context, ex execer, countries ...string) error {
+	now := time.Now()
+	touched := map[string]bool{}
+	for _, iso2 := range countries {
+		if touched[iso2] {
+			continue
+		}
+		touched[iso2] = true
+		if err := history.TouchCountry(ctx, ex, iso2, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func auditBranch(ctx context.Context, ex execer, action, actor, swift string, before, after *Branch) error {
+	return audit.Record(ctx, ex, audit.Entry{
+		ENTITY: audit.EntityBranch,
+		KEY:    swift,
+		ACTION: action,
+		ACTOR:  actor,
+		SOURCE: audit.SourceAPI,
+		BEFORE: audit.Snapshot(before),
+		AFTER:  audit.Snapshot(after),
+	})
+}
+
+// Run fn in a transaction, so a change and its audit entry are written together
+func withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			slog.ErrorContext(ctx, "Failed to roll back transaction", "error", rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Helper function to permanently remove branches deleted longer than retention ago
+func purgeDeletedBranches(ctx context.Context, retention time.Duration) (purged int64, err error) {
+	ctx, span := tracing.Start(ctx, "purge deleted branches")
+	defer func() { tracing.End(span, err) }()
+
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		// Purged rows are recorded in the audit log with their last stored values
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (entity, entity_key, action, actor, source, before_value, after_value, created_at)
+		SELECT ?, swift_code, ?, ?, ?, JSON_OBJECT('address', address, 'bankName', name, 'countryISO2', branches.country_iso2,
+			'countryName', country_name, 'isHeadquarter', IF(is_headquarter, CAST('true' AS JSON), CAST('false' AS JSON)),
+			'swiftCode', swift_code, 'deletedAt', deleted_at), NULL, UTC_TIMESTAMP(6)
+		FROM branches
+		INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+		WHERE deleted_at < UTC_TIMESTAMP() - INTERVAL ? SECOND`,
+			audit.EntityBranch, audit.ActionPurge, purgeActor, audit.SourceSystem, int64(retention.Seconds()))
+		if err != nil {
+			return err
+		}
+
+		// Purged rows disappear from responses with ?includeDeleted=true
+		_, err = tx.ExecContext(ctx, `
+		UPDATE countries SET data_version = data_version + 1, data_modified_at = UTC_TIMESTAMP(6)
+		WHERE country_iso2 IN (SELECT country_iso2 FROM branches WHERE deleted_at < UTC_TIMESTAMP() - INTERVAL ? SECOND)`,
+			int64(retention.Seconds()))
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM branches WHERE deleted_at < UTC_TIMESTAMP() - INTERVAL ? SECOND`, int64(retention.Seconds()))
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	return purged, err
+}
+
+// Actor recorded in the audit log for branches removed by the retention purge
+const purgeActor = "retention"
+
+// Time between purges of deleted branches
+var purgeInterval = time.Hour
+
+// Purge deleted branches every purgeInterval until ctx is done, meant to run in its own goroutine
+func purgeLoop(ctx context.Context, retention time.Duration) {
+	for {
+		purgeCtx, cancel := bulkContext()
+		purged, err := purgeDeletedBranches(purgeCtx, retention)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to purge deleted branches", "error", err)
+		} else if purged > 0 {
+			slog.Info("Purged deleted branches", "count", purged, "retention", retention)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(purgeInterval):
+		}
+	}
+}
+
+// Soft deleted branches are hidden unless the request asks for them with ?includeDeleted=true
+func deletedFilter(c *gin.Context) string {
+	if c.Query("includeDeleted") == "true" {
+		return ""
+	}
+	return ` AND branches.deleted_at IS NULL`
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func branchDocument(branch Branch) search.Document {
+	return search.Document{
+		ADDRESS:             branch.ADDRESS,
+		NAME:                branch.NAME,
+		COUNTRY_ISO2_CODEID: branch.COUNTRY_ISO2_CODEID,
+		COUNTRY_NAME:        branch.COUNTRY_NAME,
+		IS_HEADQUARTER:      branch.IS_HEADQUARTER,
+		SWIFT_CODE:          branch.SWIFT_CODE,
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/cache"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/httpcache"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheStatsResponse struct {
+	cache.Stats
+	HIT_RATIO float64 `json:"hitRatio"`
+}
+
+// Rendered single code and country responses, off until main sets it up
+var responseCache cache.Cache = cache.Nop{}
+
+// Invalidations bump the generation, so a response read before one is not cached after it.
+// The lock makes checking the generation and storing one step
+var (
+	cacheMu         sync.Mutex
+	cacheGeneration uint64
+)
+
+// Gin context key of the generation a cache miss was seen in
+const cacheGenerationKey = "cacheGeneration"
+
+// Build response cache from configuration, size 0 turns caching off
+func loadResponseCache(conf config.Cache) cache.Cache {
+	if conf.SIZE == 0 {
+		return cache.Nop{}
+	}
+	return cache.NewLRU(conf.SIZE, conf.TTL)
+}
+
+// Cache key of a single code response, empty when the request must not be cached.
+// Only plain requests for upper case codes are cached, responses echo the code as requested
+func branchCacheKey(c *gin.Context, swift string) string {
+	if c.Request.URL.RawQuery != "" || swift != strings.ToUpper(swift) {
+		return ""
+	}
+	return "branch:" + swift
+}
+
+// Cache key of a country response, empty when the request must not be cached.
+// Key carries the data version so a cached body always matches the ETag sent with it
+func countryCacheKey(c *gin.Context, iso2 string, version int64) string {
+	if c.Request.URL.RawQuery != "" {
+		return ""
+	}
+	return "country:" + strings.ToUpper(iso2) + ":" + strconv.FormatInt(version, 10)
+}
+
+// Strong ETag of a country response, query parameters change the response so they change the tag
+func countryETag(c *gin.Context, iso2 string, version int64) string {
+	etag := strings.ToUpper(iso2) + "-" + strconv.FormatInt(version, 10)
+	if query := c.Request.URL.RawQuery; query != "" {
+		sum := sha256.Sum256([]byte(query))
+		etag += "-" + hex.EncodeToString(sum[:8])
+	}
+	return `"` + etag + `"`
+}
+
+// Per route Cache-Control from configuration, country responses are revalidated by default
+func loadCacheControl(conf config.Cache) (map[string]string, error) {
+	return httpcache.ParseCacheControl(conf.CACHE_CONTROL_ROUTES)
+}
+
+// Write cached response, returns false on a miss
+func serveCached(c *gin.Context, key string) bool {
+	if key == "" {
+		return false
+	}
+	value, ok := responseCache.Get(key)
+	if !ok {
+		cacheMu.Lock()
+		c.Set(cacheGenerationKey, cacheGeneration)
+		cacheMu.Unlock()
+		return false
+	}
+	etag, body, _ := bytes.Cut(value, []byte("\n"))
+	if len(etag) > 0 {
+		c.Header("ETag", string(etag))
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	return true
+}
+
+// Write response the same way IndentedJSON does and keep it under key, together with the ETag already set.
+// Nothing is kept when the cache was invalidated since the miss, the response may be older than the write
+func respondCached(c *gin.Context, key string, value any) {
+	body, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to render cached response", "error", err)
+		c.IndentedJSON(http.StatusOK, value)
+		return
+	}
+	if generation, ok := c.Get(cacheGenerationKey); ok && key != "" {
+		cacheMu.Lock()
+		if generation.(uint64) == cacheGeneration {
+			responseCache.Set(key, append([]byte(c.Writer.Header().Get("ETag")+"\n"), body...))
+		}
+		cacheMu.Unlock()
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// Drop cached responses a branch write can change: the branch, its headquarter and,
+// since the old country of the branch is not known here, every country response
+func invalidateBranch(swift string) {
+	swift = strings.ToUpper(swift)
+	keys := []string{"branch:" + swift}
+	if len(swift) == 11 {
+		keys = append(keys, "branch:"+swift[:8]+"XXX")
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cacheGeneration++
+	responseCache.Delete(keys...)
+	responseCache.DeletePrefix("country:")
+}
+
+// Drop every cached response, after imports and country renames
+func invalidateAll() {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cacheGeneration++
+	responseCache.DeletePrefix("")
+}
+
+func getCacheStats(c *gin.Context) {
+	stats := responseCache.Stats()
+	c.IndentedJSON(http.StatusOK, CacheStatsResponse{stats, stats.HitRatio()})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returned by write helpers when the stored row version is not the one the client expected
+var errVersionMismatch = errors.New("branch row version does not match If-Match")
+
+// Set by REQUIRE_IF_MATCH=true, updates and deletes without If-Match are rejected then
+var requireIfMatch bool
+
+// Strong ETag of a branch response, starting with the row version. Query parameters and the branches
+// under a headquarter change the response without changing the row, so they add a hash to the tag
+func branchETag(c *gin.Context, version int64, branches []Branch) string {
+	etag := strconv.FormatInt(version, 10)
+	if query := c.Request.URL.RawQuery; query != "" || branches != nil {
+		hash := sha256.New()
+		hash.Write([]byte(query))
+		json.NewEncoder(hash).Encode(branches)
+		etag += "-" + hex.EncodeToString(hash.Sum(nil)[:8])
+	}
+	return `"` + etag + `"`
+}
+
+// Row version expected by an If-Match value, 0 when any version may be changed.
+// Only the row version of the tag is compared, returns false when the value can never match a branch ETag, weak tags included
+func parseIfMatch(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "*" {
+		return 0, true
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, false
+	}
+	row, _, _ := strings.Cut(value[1:len(value)-1], "-")
+	version, err := strconv.ParseInt(row, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// Check an If-Match value of a modifying request, returns the expected row version or the status and message to reject it with
+func checkIfMatch(value, swift string) (int64, int, string) {
+	if value == "" && requireIfMatch {
+		return 0, http.StatusPreconditionRequired, "Missing If-Match for branch " + swift + ", send the ETag from GET /v1/swift-codes/" + swift
+	}
+	version, ok := parseIfMatch(value)
+	if !ok {
+		return 0, http.StatusPreconditionFailed, versionMismatchMessage(swift)
+	}
+	return version, http.StatusOK, ""
+}
+
+// Same check for If-Match header of a single branch request, writes the rejection
+func ifMatchVersion(c *gin.Context, swift string) (int64, bool) {
+	version, status, message := checkIfMatch(c.GetHeader("If-Match"), swift)
+	if status != http.StatusOK {
+		respondError(c, status, message, nil)
+		return 0, false
+	}
+	return version, true
+}
+
+func versionMismatchMessage(swift string) string {
+	return "Branch " + swift + " was changed by someone else, get it again for the current ETag"
+}
+
+// Add row version condition to a branch UPDATE, version 0 matches any row
+func matchVersion(query string, args []any, version int64) (string, []any) {
+	if version == 0 {
+		return query, args
+	}
+	return query + ` AND row_version = ?`, append(args, version)
+}
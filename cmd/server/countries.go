@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/audit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/history"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/iso3166"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/text/language"
+)
+
+type CountrySummary struct {
+	COUNTRY_ISO2_CODEID string           `json:"countryISO2"`
+	COUNTRY_NAME        string           `json:"countryName"`
+	COUNTRY_DETAILS     *iso3166.Country `json:"countryDetails,omitempty"`
+	BRANCH_COUNT        int              `json:"branchCount"`
+	HEADQUARTER_COUNT   int              `json:"headquarterCount"`
+}
+
+type CountryRequest struct {
+	COUNTRY_ISO2_CODEID string `json:"countryISO2"`
+	COUNTRY_NAME        string `json:"countryName"`
+}
+
+const countrySummaryQuery = `
+	SELECT countries.country_iso2, country_name, COUNT(swift_code), COALESCE(SUM(is_headquarter), 0)
+	FROM countries
+	LEFT JOIN branches ON branches.country_iso2 = countries.country_iso2 AND branches.deleted_at IS NULL`
+
+func getCountries(c *gin.Context) {
+	lang, ok := requestLanguage(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, countrySummaryQuery+`
+	GROUP BY countries.country_iso2, country_name
+	ORDER BY countries.country_iso2`)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query countries", err)
+		return
+	}
+	defer rows.Close()
+
+	countries := []CountrySummary{}
+	for rows.Next() {
+		var country CountrySummary
+		if err := rows.Scan(&country.COUNTRY_ISO2_CODEID, &country.COUNTRY_NAME,
+			&country.BRANCH_COUNT, &country.HEADQUARTER_COUNT); err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
+			return
+		}
+		country.COUNTRY_DETAILS = countryDetails(country.COUNTRY_ISO2_CODEID, lang)
+		countries = append(countries, country)
+	}
+	if err := rows.Err(); storageFailure(c, err) {
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to read countries", err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, countries)
+}
+
+func getCountry(c *gin.Context) {
+	iso2 := strings.ToUpper(c.Param("countryISO2code"))
+	lang, ok := requestLanguage(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	country, err := queryCountry(ctx, db, iso2)
+	if err == sql.ErrNoRows {
+		respondError(c, http.StatusNotFound, "Country "+iso2+" not found", nil)
+		return
+	}
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query country "+iso2, err)
+		return
+	}
+	country.COUNTRY_DETAILS = countryDetails(country.COUNTRY_ISO2_CODEID, lang)
+
+	c.IndentedJSON(http.StatusOK, country)
+}
+
+func postCountry(c *gin.Context) {
+	var country CountryRequest
+	if err := c.BindJSON(&country); err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data correct?", err)
+		return
+	}
+
+	// Only ISO 3166 codes are accepted, name defaults to the ISO short name
+	iso, ok := iso3166.Lookup(country.COUNTRY_ISO2_CODEID)
+	if !ok || len(country.COUNTRY_ISO2_CODEID) != 2 {
+		respondError(c, http.StatusBadRequest, "Failed to insert country: Not an ISO 3166 alpha-2 code "+country.COUNTRY_ISO2_CODEID, nil)
+		return
+	}
+	country.COUNTRY_ISO2_CODEID = iso.ALPHA2
+	country.COUNTRY_NAME = normalizeCountryName(iso, country.COUNTRY_NAME)
+
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	err := withTx(ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO countries (country_iso2, country_name) VALUES (?, ?)`
+		if _, err := tx.ExecContext(ctx, query, country.COUNTRY_ISO2_CODEID, country.COUNTRY_NAME); err != nil {
+			return err
+		}
+		return auditCountry(ctx, tx, audit.ActionCreate, actorOf(c), nil, &country)
+	})
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+		respondError(c, http.StatusBadRequest, "Failed to insert country: Already exists "+country.COUNTRY_ISO2_CODEID, nil)
+		return
+	}
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to insert country "+country.COUNTRY_ISO2_CODEID, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully added country to database!"})
+}
+
+func putCountry(c *gin.Context) {
+	iso2 := strings.ToUpper(c.Param("countryISO2code"))
+
+	var country CountryRequest
+	if err := c.BindJSON(&country); err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data correct?", err)
+		return
+	}
+	if iso, ok := iso3166.Lookup(iso2); ok {
+		country.COUNTRY_NAME = normalizeCountryName(iso, country.COUNTRY_NAME)
+	} else {
+		country.COUNTRY_NAME = strings.ToUpper(strings.TrimSpace(country.COUNTRY_NAME))
+	}
+	if strings.TrimSpace(country.COUNTRY_NAME) == "" {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data complete?", nil)
+		return
+	}
+	if country.COUNTRY_ISO2_CODEID != "" && strings.ToUpper(country.COUNTRY_ISO2_CODEID) != iso2 {
+		respondError(c, http.StatusBadRequest, "Country code in body does not match "+iso2, nil)
+		return
+	}
+
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	stored, err := queryCountry(ctx, db, iso2)
+	if err == sql.ErrNoRows {
+		respondError(c, http.StatusNotFound, "Country "+iso2+" not found", nil)
+		return
+	} else if storageFailure(c, err) {
+		return
+	} else if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query country "+iso2, err)
+		return
+	}
+
+	country.COUNTRY_ISO2_CODEID = iso2
+	err = withTx(ctx, func(tx *sql.Tx) error {
+		query := `UPDATE countries SET country_name = ? WHERE country_iso2 = ?`
+		if _, err := tx.ExecContext(ctx, query, country.COUNTRY_NAME, iso2); err != nil {
+			return err
+		}
+		if err := history.TouchCountry(ctx, tx, iso2, time.Now()); err != nil {
+			return err
+		}
+		before := CountryRequest{stored.COUNTRY_ISO2_CODEID, stored.COUNTRY_NAME}
+		return auditCountry(ctx, tx, audit.ActionUpdate, actorOf(c), &before, &country)
+	})
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+		respondError(c, http.StatusBadRequest, "Failed to update country: Name already used "+country.COUNTRY_NAME, nil)
+		return
+	}
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to update country "+iso2, err)
+		return
+	}
+
+	// Indexed and cached branches carry the country name
+	bulkCtx, bulkCancel := bulkContext()
+	defer bulkCancel()
+	if err := loadSearchIndexes(bulkCtx); err != nil {
+		slog.ErrorContext(ctx, "Failed to rebuild search indexes", "error", err)
+	}
+	invalidateAll()
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully updated country in database!"})
+}
+
+// Helper function to get a single country with its branch counts, returns sql.ErrNoRows for unknown codes
+func queryCountry(ctx context.Context, ex execer, iso2 string) (CountrySummary, error) {
+	var country CountrySummary
+	err := ex.QueryRowContext(ctx, countrySummaryQuery+`
+	WHERE countries.country_iso2 = ?
+	GROUP BY countries.country_iso2, country_name`, iso2).Scan(&country.COUNTRY_ISO2_CODEID, &country.COUNTRY_NAME,
+		&country.BRANCH_COUNT, &country.HEADQUARTER_COUNT)
+	country.COUNTRY_DETAILS = countryDetails(country.COUNTRY_ISO2_CODEID, language.Und)
+	return country, err
+}
+
+// ISO 3166 country that can be registered for a branch, the submitted name has to be one of its ISO names
+func registrableCountry(iso2, name string) (iso3166.Country, bool) {
+	iso, ok := iso3166.Lookup(iso2)
+	return iso, ok && len(iso2) == 2 && iso.MatchesName(name)
+}
+
+// Helper function to insert a country from ISO 3166 data, meant to run in the transaction writing the branch
+// so it is rolled back with it. No-op when the country was registered meanwhile
+func registerCountry(ctx context.Context, ex execer, iso2, actor string) error {
+	iso, _ := iso3166.Lookup(iso2)
+	country := CountryRequest{iso.ALPHA2, iso.UpperName()}
+	query := `INSERT IGNORE INTO countries (country_iso2, country_name) VALUES (?, ?)`
+	res, err := ex.ExecContext(ctx, query, country.COUNTRY_ISO2_CODEID, country.COUNTRY_NAME)
+	if err != nil {
+		return err
+	}
+	if inserted, err := res.RowsAffected(); err != nil || inserted == 0 {
+		return err
+	}
+	slog.InfoContext(ctx, "Registered country", "countryISO2", iso.ALPHA2, "countryName", iso.UpperName())
+	return auditCountry(ctx, ex, audit.ActionCreate, actor, nil, &country)
+}
+
+// Submitted country name has to be the stored name or one of the ISO 3166 names, case is ignored
+func countryNameMatches(country CountrySummary, name string) bool {
+	if strings.EqualFold(strings.TrimSpace(name), country.COUNTRY_NAME) {
+		return true
+	}
+	return country.COUNTRY_DETAILS != nil && country.COUNTRY_DETAILS.MatchesName(name)
+}
+
+// Names matching ISO 3166 data are stored as the uppercase ISO short name, like the CSV file does
+func normalizeCountryName(iso iso3166.Country, name string) string {
+	if strings.TrimSpace(name) == "" || iso.MatchesName(name) {
+		return iso.UpperName()
+	}
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+func auditCountry(ctx context.Context, ex execer, action, actor string, before, after *CountryRequest) error {
+	return audit.Record(ctx, ex, audit.Entry{
+		ENTITY: audit.EntityCountry,
+		KEY:    after.COUNTRY_ISO2_CODEID,
+		ACTION: action,
+		ACTOR:  actor,
+		SOURCE: audit.SourceAPI,
+		BEFORE: audit.Snapshot(before),
+		AFTER:  audit.Snapshot(after),
+	})
+}
+
+// ISO 3166 data of a country, with its name in lang unless lang is language.Und
+func countryDetails(iso2 string, lang language.Tag) *iso3166.Country {
+	if iso, ok := iso3166.Lookup(iso2); ok && len(iso2) == 2 {
+		iso = iso.Localize(lang)
+		return &iso
+	}
+	return nil
+}
+
+// Language of localized country names from ?lang=, answers 400 for unknown languages
+func requestLanguage(c *gin.Context) (language.Tag, bool) {
+	lang, err := iso3166.ParseLanguage(c.Query("lang"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Unknown language "+c.Query("lang")+", use a tag like de or pt-BR", err)
+		return language.Und, false
+	}
+	return lang, true
+}
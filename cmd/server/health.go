@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SchemaStatus struct {
+	UP       bool   `json:"up"`
+	VERSION  int    `json:"version"`
+	EXPECTED int    `json:"expected"`
+	ERROR    string `json:"error,omitempty"`
+}
+
+type ImportStatus struct {
+	UP       bool       `json:"up"`
+	POLICY   string     `json:"policy"`
+	FINISHED *time.Time `json:"finishedAt,omitempty"`
+}
+
+type Components struct {
+	DATABASE database.Status `json:"database"`
+	SCHEMA   SchemaStatus    `json:"schema"`
+	IMPORT   ImportStatus    `json:"import"`
+}
+
+type Readiness struct {
+	READY      bool       `json:"ready"`
+	COMPONENTS Components `json:"components"`
+}
+
+// Startup import policy, reported by readiness
+var importPolicy string
+
+// When the startup import and search index load finished, nil while they run
+var startupFinished atomic.Pointer[time.Time]
+
+// Liveness, answers as long as the process serves requests
+func getHealthz(c *gin.Context) {
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Server is alive"})
+}
+
+// Readiness, 503 until the database is reachable, the schema is migrated and the startup import finished.
+// Probes need no credentials, so driver errors are only logged and components report a generic state
+func getReadyz(c *gin.Context) {
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	var readiness Readiness
+	readiness.COMPONENTS.DATABASE = dbMonitor.Status()
+	if !readiness.COMPONENTS.DATABASE.UP {
+		readiness.COMPONENTS.DATABASE.ERROR = "Database unreachable"
+	}
+
+	schema := SchemaStatus{EXPECTED: database.SchemaVersion()}
+	version, err := database.CurrentVersion(ctx, db)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to read schema version", "error", err)
+		schema.ERROR = "Failed to read schema version"
+	}
+	schema.VERSION = version
+	schema.UP = err == nil && version == schema.EXPECTED
+	readiness.COMPONENTS.SCHEMA = schema
+
+	finished := startupFinished.Load()
+	readiness.COMPONENTS.IMPORT = ImportStatus{UP: finished != nil, POLICY: importPolicy, FINISHED: finished}
+
+	readiness.READY = readiness.COMPONENTS.DATABASE.UP && schema.UP && finished != nil
+	if !readiness.READY {
+		c.IndentedJSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+	c.IndentedJSON(http.StatusOK, readiness)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/history"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+type HistoryResponse struct {
+	SWIFT_CODE string            `json:"swiftCode"`
+	VERSIONS   []history.Version `json:"versions"`
+}
+
+func getBranchHistory(c *gin.Context) {
+	swift := c.Param("swift-code")
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	versions, err := history.List(ctx, db, swift)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query history of "+swift, err)
+		return
+	}
+	if len(versions) == 0 {
+		respondError(c, http.StatusNotFound, "No history for branch "+swift, nil)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, HistoryResponse{swift, versions})
+}
+
+// Time from ?asOf=, asOf is false if the request does not ask for past data and ok is false for a wrong time
+func parseAsOf(c *gin.Context) (at time.Time, asOf bool, ok bool) {
+	value, asOf := c.GetQuery("asOf")
+	if !asOf {
+		return time.Time{}, false, true
+	}
+	at, err := parseTime(value)
+	return at, true, err == nil && !at.IsZero()
+}
+
+// Branch or headquarter with its branches as stored at time at
+func getBranchBySwiftAsOf(ctx context.Context, c *gin.Context, swift string, at time.Time, lang language.Tag) {
+	version, err := history.Find(ctx, db, swift, at)
+	if err == sql.ErrNoRows {
+		respondError(c, http.StatusNotFound, "Branch "+swift+" did not exist at "+at.Format(time.RFC3339), nil)
+		return
+	}
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query history of "+swift, err)
+		return
+	}
+
+	branch := versionBranch(version, lang)
+	if !branch.IS_HEADQUARTER {
+		c.IndentedJSON(http.StatusOK, branch)
+		return
+	}
+
+	swiftPrefix, _ := strings.CutSuffix(swift, "XXX")
+	versions, err := history.FindBranches(ctx, db, swiftPrefix, at)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query branches under a headquarter "+swift, err)
+		return
+	}
+	var branches []Branch
+	for _, version := range versions {
+		branches = append(branches, versionBranch(version, lang))
+	}
+
+	c.IndentedJSON(http.StatusOK, Headquarter{branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID,
+		branch.COUNTRY_NAME, branch.COUNTRY_DETAILS, branch.IS_HEADQUARTER, branch.SWIFT_CODE, nil, branches})
+}
+
+// Branches of a country as stored at time at
+func getBranchesByCountryAsOf(ctx context.Context, c *gin.Context, country Country, at time.Time, lang language.Tag) {
+	versions, err := history.FindByCountry(ctx, db, country.COUNTRY_ISO2_CODEID, at)
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to query country swift codes: "+country.COUNTRY_ISO2_CODEID, err)
+		return
+	}
+
+	for _, version := range versions {
+		country.SWIFT_CODES = append(country.SWIFT_CODES, CountryBranch{ADDRESS: version.ADDRESS, NAME: version.NAME,
+			COUNTRY_ISO2_CODEID: version.COUNTRY_ISO2_CODEID, IS_HEADQUARTER: version.IS_HEADQUARTER, SWIFT_CODE: version.SWIFT_CODE})
+	}
+	// Country may have been renamed since
+	if len(versions) > 0 {
+		country.COUNTRY_NAME = versions[0].COUNTRY_NAME
+	}
+	country.COUNTRY_DETAILS = countryDetails(country.COUNTRY_ISO2_CODEID, lang)
+
+	c.IndentedJSON(http.StatusOK, country)
+}
+
+func versionBranch(version history.Version, lang language.Tag) Branch {
+	return Branch{
+		ADDRESS:             version.ADDRESS,
+		NAME:                version.NAME,
+		COUNTRY_ISO2_CODEID: version.COUNTRY_ISO2_CODEID,
+		COUNTRY_NAME:        version.COUNTRY_NAME,
+		COUNTRY_DETAILS:     countryDetails(version.COUNTRY_ISO2_CODEID, lang),
+		IS_HEADQUARTER:      version.IS_HEADQUARTER,
+		SWIFT_CODE:          version.SWIFT_CODE,
+	}
+}
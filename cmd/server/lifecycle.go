@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Canceled when shutdown starts, imports and background jobs derive their context from it.
+// Requests keep their own context so they can finish while the server drains
+var lifecycle = context.Background()
+
+// Serve on listener until ctx is done, then stop accepting connections and wait up to drain for in-flight requests.
+// Connections still open after drain are closed
+func serve(ctx context.Context, server *http.Server, listener net.Listener, drain time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, waiting for in-flight requests", "drainTimeout", drain)
+	drainCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := server.Shutdown(drainCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("requests still running after %s were cut off: %w", drain, err)
+	}
+	slog.Info("All requests finished")
+	return nil
+}
+
+// Export spans still buffered, waits at most 5 seconds for the collector
+func flushTracing(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		slog.Error("Failed to export remaining spans", "error", err)
+	}
+}
+
+// Close the pool after the server stopped
+func closeDatabase() {
+	if err := db.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+		return
+	}
+	slog.Info("Closed database connections")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxLookupCodes = 5000
+
+const (
+	lookupFound    = "found"
+	lookupNotFound = "notFound"
+	lookupInvalid  = "invalid"
+)
+
+type LookupRequest struct {
+	SWIFT_CODES []string `json:"swiftCodes"`
+}
+
+type LookupResult struct {
+	QUERY  string  `json:"query"`
+	STATUS string  `json:"status"`
+	BRANCH *Branch `json:"branch,omitempty"`
+}
+
+type LookupResponse struct {
+	FOUND     int            `json:"found"`
+	NOT_FOUND int            `json:"notFound"`
+	INVALID   int            `json:"invalid"`
+	RESULTS   []LookupResult `json:"results"`
+}
+
+func lookupBranches(c *gin.Context) {
+	var request LookupRequest
+	if err := c.BindJSON(&request); err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data correct?", err)
+		return
+	}
+	if len(request.SWIFT_CODES) == 0 || len(request.SWIFT_CODES) > maxLookupCodes {
+		respondError(c, http.StatusBadRequest, "Lookup needs between 1 and "+strconv.Itoa(maxLookupCodes)+" swift codes", nil)
+		return
+	}
+	lang, ok := requestLanguage(c)
+	if !ok {
+		return
+	}
+
+	// Collect distinct valid codes for a single IN query
+	var codes []any
+	seen := map[string]bool{}
+	for _, query := range request.SWIFT_CODES {
+		swift, ok := normalizeSwiftCode(query)
+		if ok && !seen[swift] {
+			seen[swift] = true
+			codes = append(codes, swift)
+		}
+	}
+
+	branches := map[string]Branch{}
+	if len(codes) > 0 {
+		ctx, cancel := readContext(c)
+		defer cancel()
+
+		rows, err := db.QueryContext(ctx, `
+		SELECT address, name, branches.country_iso2, country_name, swift_code, is_headquarter
+		FROM branches
+		INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+		WHERE deleted_at IS NULL AND swift_code IN (?`+strings.Repeat(", ?", len(codes)-1)+`)`, codes...)
+		if storageFailure(c, err) {
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to query swift codes", err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var branch Branch
+			if err := rows.Scan(&branch.ADDRESS, &branch.NAME, &branch.COUNTRY_ISO2_CODEID,
+				&branch.COUNTRY_NAME, &branch.SWIFT_CODE, &branch.IS_HEADQUARTER); err != nil {
+				respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
+				return
+			}
+			branch.COUNTRY_DETAILS = countryDetails(branch.COUNTRY_ISO2_CODEID, lang)
+			branches[branch.SWIFT_CODE] = branch
+		}
+		// Rows stop early when the query runs out of time or the read fails, a partial result is not an answer
+		if err := rows.Err(); storageFailure(c, err) {
+			return
+		} else if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to read swift codes", err)
+			return
+		}
+	}
+
+	// One result per submitted code, in request order
+	response := LookupResponse{RESULTS: make([]LookupResult, 0, len(request.SWIFT_CODES))}
+	for _, query := range request.SWIFT_CODES {
+		result := LookupResult{QUERY: query}
+		swift, ok := normalizeSwiftCode(query)
+		if branch, found := branches[swift]; !ok {
+			result.STATUS = lookupInvalid
+			response.INVALID++
+		} else if found {
+			result.STATUS = lookupFound
+			result.BRANCH = &branch
+			response.FOUND++
+		} else {
+			result.STATUS = lookupNotFound
+			response.NOT_FOUND++
+		}
+		response.RESULTS = append(response.RESULTS, result)
+	}
+
+	c.IndentedJSON(http.StatusOK, response)
+}
+
+// Uppercase and validate a SWIFT code, 8 character codes point to the primary office and get the XXX suffix
+func normalizeSwiftCode(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) == 8 {
+		code += "XXX"
+	}
+	return code, isSwiftCode(code)
+}
+
+// SWIFT code is 4 character institution code, 2 letter country code, 2 character location and 3 character branch code
+func isSwiftCode(code string) bool {
+	if len(code) != 11 || !isBankCode(code[:4]) {
+		return false
+	}
+	for i, r := range code[4:] {
+		letter := r >= 'A' && r <= 'Z'
+		digit := r >= '0' && r <= '9'
+		if i < 2 && !letter {
+			return false // Country code
+		}
+		if !letter && !digit {
+			return false
+		}
+	}
+	return true
+}
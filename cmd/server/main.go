@@ -2,47 +2,74 @@ package main
 
 import (
 	"Michal_Gomulczak_Assessment/SWIFT-API/internal/database"
+	"context"
 	"database/sql"
+	"errors"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"slices"
 	"strings"
-
-	"Michal_Gomulczak_Assessment/SWIFT-API/internal/parser"
+	"syscall"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/cache"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/httpcache"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/iso3166"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/logging"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/metrics"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/ratelimit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/search"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Branch struct {
-	ADDRESS             string `json:"address"`
-	NAME                string `json:"bankName"`
-	COUNTRY_ISO2_CODEID string `json:"countryISO2"`
-	COUNTRY_NAME        string `json:"countryName"`
-	IS_HEADQUARTER      bool   `json:"isHeadquarter"`
-	SWIFT_CODE          string `json:"swiftCode"`
+	ADDRESS             string           `json:"address"`
+	NAME                string           `json:"bankName"`
+	COUNTRY_ISO2_CODEID string           `json:"countryISO2"`
+	COUNTRY_NAME        string           `json:"countryName"`
+	COUNTRY_DETAILS     *iso3166.Country `json:"countryDetails,omitempty"`
+	IS_HEADQUARTER      bool             `json:"isHeadquarter"`
+	SWIFT_CODE          string           `json:"swiftCode"`
+	DELETED_AT          *time.Time       `json:"deletedAt,omitempty"`
 }
 
 type CountryBranch struct {
-	ADDRESS             string `json:"address"`
-	NAME                string `json:"bankName"`
-	COUNTRY_ISO2_CODEID string `json:"countryISO2"`
-	IS_HEADQUARTER      bool   `json:"isHeadquarter"`
-	SWIFT_CODE          string `json:"swiftCode"`
+	ADDRESS             string     `json:"address"`
+	NAME                string     `json:"bankName"`
+	COUNTRY_ISO2_CODEID string     `json:"countryISO2"`
+	IS_HEADQUARTER      bool       `json:"isHeadquarter"`
+	SWIFT_CODE          string     `json:"swiftCode"`
+	DELETED_AT          *time.Time `json:"deletedAt,omitempty"`
 }
 
 type Headquarter struct {
-	ADDRESS            string   `json:"address"`
-	NAME               string   `json:"bankName"`
-	OUNTRY_ISO2_CODEID string   `json:"countryISO2"`
-	COUNTRY_NAME       string   `json:"countryName"`
-	IS_HEADQUARTER     bool     `json:"isHeadquarter"`
-	SWIFT_CODE         string   `json:"swiftCode"`
-	BRANCHES           []Branch `json:"branches"`
+	ADDRESS            string           `json:"address"`
+	NAME               string           `json:"bankName"`
+	OUNTRY_ISO2_CODEID string           `json:"countryISO2"`
+	COUNTRY_NAME       string           `json:"countryName"`
+	COUNTRY_DETAILS    *iso3166.Country `json:"countryDetails,omitempty"`
+	IS_HEADQUARTER     bool             `json:"isHeadquarter"`
+	SWIFT_CODE         string           `json:"swiftCode"`
+	DELETED_AT         *time.Time       `json:"deletedAt,omitempty"`
+	BRANCHES           []Branch         `json:"branches"`
 }
 
 type Country struct {
-	COUNTRY_ISO2_CODEID string          `json:"countryISO2"`
-	COUNTRY_NAME        string          `json:"countryName"`
-	SWIFT_CODES         []CountryBranch `json:"swiftCodes"`
+	COUNTRY_ISO2_CODEID string           `json:"countryISO2"`
+	COUNTRY_NAME        string           `json:"countryName"`
+	COUNTRY_DETAILS     *iso3166.Country `json:"countryDetails,omitempty"`
+	SWIFT_CODES         []CountryBranch  `json:"swiftCodes"`
 }
 
 type MessageResponse struct {
@@ -51,148 +78,511 @@ type MessageResponse struct {
 
 var db *sql.DB
 
+// How long soft deleted branches are kept before they are purged
+var deletedRetention time.Duration
+
+// Reachability of the database after startup, losing it doesn't stop the server
+var dbMonitor *database.Monitor
+
+// Prometheus metrics of requests, the connection pool, imports and the response cache
+var serverMetrics = metrics.New()
+
+// Paths polled by orchestrators and Prometheus, left out of the access log
+var pollPaths = []string{"/healthz", "/readyz", "/metrics"}
+
+// Register unknown countries from ISO 3166 data when a branch is posted for them
+var autoRegisterCountries bool
+
 func main() {
-	gin.SetMode(gin.ReleaseMode)
+	conf, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, conf.LOG.LEVEL))
+	if conf.LOG.LEVEL == config.LevelDebug {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	autoRegisterCountries = conf.IMPORT.AUTO_REGISTER_COUNTRIES
+	requireIfMatch = conf.SERVER.REQUIRE_IF_MATCH
+	deletedRetention = conf.SERVER.DELETED_RETENTION
+	importCSVPath = conf.IMPORT.CSV_PATH
+	readTimeout, writeTimeout, bulkTimeout = conf.TIMEOUTS.READ, conf.TIMEOUTS.WRITE, conf.TIMEOUTS.BULK
 
-	var err error
-	db, err = database.Connect()
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.TRACING)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		return
+	}
+	// Runs after the database is closed, so spans of the shutdown are exported too
+	defer flushTracing(shutdownTracing)
+
+	// SIGTERM or SIGINT starts shutdown, a second one kills the server without waiting
+	var stop context.CancelFunc
+	lifecycle, stop = signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-lifecycle.Done()
+		stop()
+	}()
+
+	ctx, cancel := context.WithTimeout(lifecycle, conf.DATABASE.CONNECT_TIMEOUT)
+	db, err = database.Connect(ctx, conf.DATABASE)
+	cancel()
 	if err != nil {
-		log.Println(err)
+		slog.Error("Failed to connect to database", "error", err)
 		return
 	}
-	err = parser.Parse(db)
+	defer closeDatabase()
+	dbMonitor = database.NewMonitor(db, conf.DATABASE.PING_INTERVAL, database.RetryOf(conf.DATABASE))
+	go dbMonitor.Run(lifecycle)
+	migrateCtx, cancel := bulkContext()
+	err = database.Migrate(migrateCtx, db)
+	cancel()
 	if err != nil {
-		log.Println(err) // It's normal to get errors here since some data might be already parsed
+		slog.Error("Failed to migrate database", "error", err)
+		return
 	}
+	importPolicy = conf.IMPORT.POLICY
+	responseCache = loadResponseCache(conf.CACHE)
+	serverMetrics.RegisterDB(db, "swift")
+	serverMetrics.RegisterCache(func() cache.Stats { return responseCache.Stats() })
+
+	go purgeLoop(lifecycle, deletedRetention)
 
-	router := gin.Default()
-	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
-	router.GET("/v1/swift-codes/country/:countryISO2code", getBranchesByCountry)
-	router.POST("/v1/swift-codes/", postBranch)
-	router.DELETE("/v1/swift-codes/:swift-code", deleteBranch)
-	router.Run("0.0.0.0:8080")
+	keyStore = auth.NewKeyStore(db, readTimeout)
+	authenticator, err := loadAuthenticator(conf.AUTH)
+	if err != nil {
+		slog.Error("Failed to load authentication", "error", err)
+		return
+	}
+	publicReads := conf.AUTH.PUBLIC_READS
+	limits, err := loadRateLimits(conf.RATE_LIMIT)
+	if err != nil {
+		slog.Error("Failed to load rate limits", "error", err)
+		return
+	}
+	failureLimit, err := loadFailureLimit(conf.RATE_LIMIT)
+	if err != nil {
+		slog.Error("Failed to load rate limits", "error", err)
+		return
+	}
+
+	cacheControl, err := loadCacheControl(conf.CACHE)
+	if err != nil {
+		slog.Error("Failed to load Cache-Control routes", "error", err)
+		return
+	}
+
+	router := newRouter(conf.TRACING.SERVICE_NAME)
+	if err := router.SetTrustedProxies(conf.SERVER.TRUSTED_PROXIES); err != nil {
+		slog.Error("Failed to set trusted proxies", "error", err)
+		return
+	}
+	router.Use(serverMetrics.Middleware(), httpcache.CacheControl(cacheControl))
+
+	// Limit after authentication so clients with credentials get their own bucket,
+	// failed authentications are counted per IP before it so wrong keys can't be tried without limit
+	limiter := ratelimit.Middleware(limits, rateLimitClient)
+	failures := ratelimit.FailureMiddleware(failureLimit, http.StatusUnauthorized, func(c *gin.Context) string { return c.ClientIP() })
+
+	// Reads are public unless auth.publicReads is false, writes always need credentials with the route's scope
+	// Health and metrics endpoints skip authentication and rate limits so they can always be polled
+	router.GET("/healthz", getHealthz)
+	router.GET("/readyz", getReadyz)
+	router.GET("/metrics", gin.WrapH(serverMetrics.Handler()))
+
+	reads := router.Group("", failures, authenticator.Middleware(!publicReads), limiter)
+	if !publicReads {
+		reads.Use(auth.RequireScope(auth.ScopeRead))
+	}
+	reads.GET("/v1/swift-codes/search", searchBranches)
+	reads.GET("/v1/swift-codes/suggest", suggestBranches)
+	reads.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+	reads.GET("/v1/swift-codes/:swift-code/history", getBranchHistory)
+	reads.GET("/v1/swift-codes/country/:countryISO2code", getBranchesByCountry)
+	reads.POST("/v1/swift-codes/lookup", lookupBranches)
+	reads.GET("/v1/countries", getCountries)
+	reads.GET("/v1/countries/:countryISO2code", getCountry)
+	reads.GET("/v1/banks", getBanks)
+	reads.GET("/v1/banks/:bankCode", getBank)
+
+	writes := router.Group("", failures, authenticator.Middleware(true), limiter)
+	writes.POST("/v1/swift-codes/", auth.RequireScope(auth.ScopeWrite), postBranch)
+	writes.POST("/v1/swift-codes/batch", auth.RequireScope(auth.ScopeWrite), batchBranches)
+	writes.DELETE("/v1/swift-codes/:swift-code", auth.RequireScope(auth.ScopeDelete), deleteBranch)
+	writes.POST("/v1/swift-codes/:swift-code/restore", auth.RequireScope(auth.ScopeDelete), postRestoreBranch)
+	writes.POST("/v1/countries", auth.RequireScope(auth.ScopeAdmin), postCountry)
+	writes.PUT("/v1/countries/:countryISO2code", auth.RequireScope(auth.ScopeAdmin), putCountry)
+	writes.GET("/v1/audit", auth.RequireScope(auth.ScopeAdmin), getAudit)
+	writes.POST("/v1/admin/import", auth.RequireScope(auth.ScopeImport), postImport)
+	writes.GET("/v1/admin/cache", auth.RequireScope(auth.ScopeAdmin), getCacheStats)
+	writes.GET("/v1/admin/api-keys", auth.RequireScope(auth.ScopeAdmin), getAPIKeys)
+	writes.POST("/v1/admin/api-keys", auth.RequireScope(auth.ScopeAdmin), postAPIKey)
+	writes.DELETE("/v1/admin/api-keys/:id", auth.RequireScope(auth.ScopeAdmin), deleteAPIKey)
+
+	// Startup import takes the import flag before the listener starts, so an admin import can't run next to it
+	loaded, ok := startLoadData(importPolicy)
+	if !ok {
+		slog.Error("Failed to start import, another one is running")
+		return
+	}
+	// Import stops with lifecycle, the database is closed only after it returned
+	defer func() {
+		stop()
+		<-loaded
+	}()
+
+	listener, err := net.Listen("tcp", conf.SERVER.LISTEN_ADDRESS)
+	if err != nil {
+		slog.Error("Failed to listen", "address", conf.SERVER.LISTEN_ADDRESS, "error", err)
+		return
+	}
+	slog.Info("Listening", "address", listener.Addr().String())
+	if err := serve(lifecycle, &http.Server{Handler: router}, listener, conf.SERVER.SHUTDOWN_TIMEOUT); err != nil {
+		slog.Error("Server stopped", "error", err)
+	}
+}
+
+// Access log lines are info, so warn and error levels turn them off. Health checks and scrapes are never logged or traced.
+// Tracing goes before the access log so its lines carry the request's trace id
+func newRouter(serviceName string) *gin.Engine {
+	router := gin.New()
+	router.Use(logging.RequestIDs(),
+		otelgin.Middleware(serviceName, otelgin.WithFilter(func(r *http.Request) bool { return !slices.Contains(pollPaths, r.URL.Path) })),
+		logging.AccessLog(pollPaths),
+		gin.CustomRecoveryWithWriter(io.Discard, recoverPanic))
+	return router
+}
+
+// Log a handler panic with its request id and answer 500
+func recoverPanic(c *gin.Context, recovered any) {
+	slog.ErrorContext(c.Request.Context(), "Panic in handler", "panic", recovered, "stack", string(debug.Stack()))
+	respondError(c, http.StatusInternalServerError, "Internal server error", nil)
+	c.Abort()
+}
+
+// Error response with the request id, clients quote it when reporting a problem.
+// err is logged with the message, as a warning for client errors
+func respondError(c *gin.Context, status int, message string, err error) {
+	ctx := c.Request.Context()
+	if err != nil {
+		level := slog.LevelWarn
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, message, "status", status, "error", err)
+	}
+	c.IndentedJSON(status, gin.H{"message": message, "requestId": logging.RequestID(ctx)})
+}
+
+// Take the import flag and run loadData in background, done is closed when it returned and the flag is released.
+// False when another import holds the flag
+func startLoadData(policy string) (done chan struct{}, ok bool) {
+	if !importRunning.CompareAndSwap(false, true) {
+		return nil, false
+	}
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		defer importRunning.Store(false)
+		loadData(policy)
+	}()
+	return done, true
+}
+
+// Startup import and search index load, run in background so health endpoints answer meanwhile.
+// Readiness waits for it, the import endpoint refuses to run alongside it
+func loadData(policy string) {
+	ctx, cancel := bulkContext()
+	defer cancel()
+	if err := startupImport(ctx, policy); err != nil {
+		slog.Warn("Startup import failed", "error", err) // It's normal to get errors here since some data might be already parsed
+	}
+
+	// Build search indexes from whatever the import left in database
+	if err := loadSearchIndexes(ctx); err != nil {
+		slog.Error("Failed to build search indexes", "error", err)
+	}
+	invalidateAll()
+	if lifecycle.Err() != nil {
+		return
+	}
+	finished := time.Now()
+	startupFinished.Store(&finished)
+	slog.Info("Startup import finished, server is ready")
+}
+
+// Import CSV file on startup according to import policy, ifEmpty only imports into a database without branches
+func startupImport(ctx context.Context, policy string) error {
+	switch policy {
+	case config.ImportNever:
+		return nil
+	case config.ImportIfEmpty:
+		var count int
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM branches`).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			slog.Info("Skipping import, database already has branches")
+			return nil
+		}
+	}
+	_, err := runImport(ctx)
+	return err
 }
 
 func deleteBranch(c *gin.Context) {
 	swift := c.Param("swift-code")
+	version, ok := ifMatchVersion(c, swift)
+	if !ok {
+		return
+	}
 
-	query := `DELETE FROM branches WHERE swift_code = ?`
-	res, err := db.Exec(query, swift)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to delete swift " + swift + " from database"})
-		log.Println(err)
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	var found bool
+	err := withTx(ctx, func(tx *sql.Tx) (err error) {
+		found, err = removeBranch(ctx, tx, swift, version, actorOf(c))
+		return err
+	})
+	if err == errVersionMismatch {
+		respondError(c, http.StatusPreconditionFailed, versionMismatchMessage(swift), nil)
+		return
+	}
+	if storageFailure(c, err) {
 		return
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to delete swift " + swift + " from database"})
-		log.Println(err)
+	if err != nil || !found {
+		respondError(c, http.StatusBadRequest, "Failed to delete swift "+swift+" from database", err)
 		return
 	}
+	unindexBranch(swift)
+	invalidateBranch(swift)
 
 	c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully deleted branch from database!"})
 }
 
+func postRestoreBranch(c *gin.Context) {
+	swift := c.Param("swift-code")
+	version, ok := ifMatchVersion(c, swift)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	var found bool
+	err := withTx(ctx, func(tx *sql.Tx) (err error) {
+		found, err = restoreBranch(ctx, tx, swift, version, actorOf(c))
+		return err
+	})
+	if err == errVersionMismatch {
+		respondError(c, http.StatusPreconditionFailed, versionMismatchMessage(swift), nil)
+		return
+	}
+	if storageFailure(c, err) {
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Failed to restore swift "+swift, err)
+		return
+	}
+	if !found {
+		respondError(c, http.StatusNotFound, "No deleted branch "+swift+" to restore", nil)
+		return
+	}
+
+	if doc, err := search.LoadDocument(ctx, db, swift); err != nil {
+		slog.ErrorContext(ctx, "Failed to load restored branch for search", "swiftCode", swift, "error", err)
+	} else {
+		indexBranch(doc)
+	}
+	invalidateBranch(swift)
+
+	c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully restored branch!"})
+}
+
 func postBranch(c *gin.Context) {
 	var branch Branch
 
 	if err := c.BindJSON(&branch); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to bind JSON, is data correct?"})
-		log.Println(err)
+		respondError(c, http.StatusBadRequest, "Failed to bind JSON, is data correct?", err)
 		return
 	}
 
-	if branch.ADDRESS == "" || branch.COUNTRY_ISO2_CODEID == "" || branch.COUNTRY_NAME == "" || branch.NAME == "" || branch.SWIFT_CODE == "" {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to bind JSON, is data complete?"})
+	ctx, cancel := writeContext(c)
+	defer cancel()
+
+	status, message := checkBranch(&branch, "insert")
+	if status != http.StatusOK {
+		respondError(c, status, message, nil)
 		return
 	}
 
-	// Run a query to check if bank is in country which is not in database
-	query := `SELECT * FROM countries WHERE country_iso2 = ?`
-	_, err := db.Query(query, branch.COUNTRY_ISO2_CODEID)
-	if err == sql.ErrNoRows {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to insert branch: Wrong country code"})
-		log.Println(err)
+	// Insert new branch to database, with its country if that is registered for it.
+	// Country is checked in the same transaction, so it can't change before the branch is written
+	actor := actorOf(c)
+	err := withTx(ctx, func(tx *sql.Tx) error {
+		var register bool
+		if status, message, register = validateCountry(ctx, tx, &branch, "insert"); status != http.StatusOK {
+			return errors.New(message)
+		}
+		if register {
+			if err := registerCountry(ctx, tx, branch.COUNTRY_ISO2_CODEID, actor); err != nil {
+				return err
+			}
+		}
+		return insertBranch(ctx, tx, branch, actor)
+	})
+	if status != http.StatusOK {
+		respondError(c, status, message, nil)
+		return
+	}
+	if storageFailure(c, err) {
 		return
 	}
-
-	// Insert new branch to database
-	query = `INSERT INTO branches (address, name, country_iso2, is_headquarter, swift_code) VALUES (?, ?, ?, ?, ?)`
-	_, err = db.Exec(query, branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID, branch.IS_HEADQUARTER, branch.SWIFT_CODE)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to insert branch: Already exists or wrong data " + branch.SWIFT_CODE})
-		log.Println(err)
+		respondError(c, http.StatusBadRequest, "Failed to insert branch: Already exists or wrong data "+branch.SWIFT_CODE, err)
 	} else {
+		indexBranch(branchDocument(branch))
+		invalidateBranch(branch.SWIFT_CODE)
 		c.IndentedJSON(http.StatusOK, gin.H{"message": "Succesfully added branch to database!"})
 	}
 }
 
 func getBranchesByCountry(c *gin.Context) {
 	country_code := c.Param("countryISO2code")
+	lang, ok := requestLanguage(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := readContext(c)
+	defer cancel()
 
-	// Run a query to get country name
-	rows := db.QueryRow(`
-	SELECT country_iso2, country_name
+	// Run a query to get country name and data version
+	rows := db.QueryRowContext(ctx, `
+	SELECT country_iso2, country_name, data_version, data_modified_at
 	FROM countries 
 	WHERE country_iso2 = ?`, country_code)
 	var country Country
+	var version int64
+	var modified sql.NullTime
+
+	if err := rows.Scan(&country.COUNTRY_ISO2_CODEID, &country.COUNTRY_NAME, &version, &modified); err != nil {
+		if storageFailure(c, err) {
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Failed to query country name from code "+country_code, err)
+		return
+	}
+
+	// Client's copy is current if no branch of the country changed since
+	if httpcache.NotModified(c, countryETag(c, country.COUNTRY_ISO2_CODEID, version), modified.Time) {
+		return
+	}
+	cacheKey := countryCacheKey(c, country.COUNTRY_ISO2_CODEID, version)
+	if serveCached(c, cacheKey) {
+		return
+	}
 
-	if err := rows.Scan(&country.COUNTRY_ISO2_CODEID, &country.COUNTRY_NAME); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to query country name from code " + country_code})
-		log.Println(err)
+	if at, asOf, ok := parseAsOf(c); !ok {
+		respondError(c, http.StatusBadRequest, "Wrong asOf time "+c.Query("asOf")+", use RFC 3339 or YYYY-MM-DD", nil)
+		return
+	} else if asOf {
+		getBranchesByCountryAsOf(ctx, c, country, at, lang)
 		return
 	}
 
 	var countryBranches []CountryBranch
 
 	// Query country swift codes
-	countryRows, err := db.Query(`
-		SELECT address, name, branches.country_iso2, is_headquarter, swift_code
+	countryRows, err := db.QueryContext(ctx, `
+		SELECT address, name, branches.country_iso2, is_headquarter, swift_code, deleted_at
 		FROM branches 
 		INNER JOIN countries ON branches.country_iso2 = countries.country_iso2 
-		WHERE branches.country_iso2 = ?`, country_code)
+		WHERE branches.country_iso2 = ?`+deletedFilter(c), country_code)
+	if storageFailure(c, err) {
+		return
+	}
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to query country swift codes: " + country_code})
-		log.Println(err)
+		respondError(c, http.StatusBadRequest, "Failed to query country swift codes: "+country_code, err)
 		return
 	}
+	defer countryRows.Close()
 
 	for countryRows.Next() {
 		var countryBranch CountryBranch
+		var deletedAt sql.NullTime
 		if err := countryRows.Scan(&countryBranch.ADDRESS, &countryBranch.NAME, &countryBranch.COUNTRY_ISO2_CODEID,
-			&countryBranch.IS_HEADQUARTER, &countryBranch.SWIFT_CODE); err != nil {
-			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to extract data from query"})
-			log.Println(err)
+			&countryBranch.IS_HEADQUARTER, &countryBranch.SWIFT_CODE, &deletedAt); err != nil {
+			respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
 			return
 		}
+		countryBranch.DELETED_AT = timePtr(deletedAt)
 		countryBranches = append(countryBranches, countryBranch)
 	}
+	if err := countryRows.Err(); storageFailure(c, err) {
+		return
+	} else if err != nil {
+		respondError(c, http.StatusInternalServerError, "Failed to read country swift codes: "+country_code, err)
+		return
+	}
 
 	country.SWIFT_CODES = countryBranches
+	country.COUNTRY_DETAILS = countryDetails(country.COUNTRY_ISO2_CODEID, lang)
 
-	c.IndentedJSON(http.StatusOK, country)
+	respondCached(c, cacheKey, country)
 }
 
 func getBranchBySwift(c *gin.Context) {
 	swift := c.Param("swift-code")
+	lang, ok := requestLanguage(c)
+	if !ok {
+		return
+	}
+	cacheKey := branchCacheKey(c, swift)
+	if serveCached(c, cacheKey) {
+		return
+	}
+	ctx, cancel := readContext(c)
+	defer cancel()
+
+	if at, asOf, ok := parseAsOf(c); !ok {
+		respondError(c, http.StatusBadRequest, "Wrong asOf time "+c.Query("asOf")+", use RFC 3339 or YYYY-MM-DD", nil)
+		return
+	} else if asOf {
+		getBranchBySwiftAsOf(ctx, c, swift, at, lang)
+		return
+	}
 
 	// Query to get "base" branch, either headquarter or branch
-	rows := db.QueryRow(`
-	SELECT address, name, branches.country_iso2, country_name, is_headquarter 
+	rows := db.QueryRowContext(ctx, `
+	SELECT address, name, branches.country_iso2, country_name, is_headquarter, deleted_at, row_version 
 	FROM branches 
 	INNER JOIN countries ON branches.country_iso2 = countries.country_iso2 
-	WHERE branches.swift_code = ?`, swift)
+	WHERE branches.swift_code = ?`+deletedFilter(c), swift)
 
 	var branch Branch
+	var deletedAt sql.NullTime
+	var rowVersion int64
 	if err := rows.Scan(&branch.ADDRESS, &branch.NAME, &branch.COUNTRY_ISO2_CODEID,
-		&branch.COUNTRY_NAME, &branch.IS_HEADQUARTER); err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to extract data from query"})
-		log.Println(err)
+		&branch.COUNTRY_NAME, &branch.IS_HEADQUARTER, &deletedAt, &rowVersion); err != nil {
+		if storageFailure(c, err) {
+			return
+		}
+		respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
 		return
 	}
 	branch.SWIFT_CODE = swift
+	branch.DELETED_AT = timePtr(deletedAt)
+	branch.COUNTRY_DETAILS = countryDetails(branch.COUNTRY_ISO2_CODEID, lang)
 	if !branch.IS_HEADQUARTER {
-		c.IndentedJSON(http.StatusOK, branch)
+		// Clients send it back in If-Match when they change the branch
+		c.Header("ETag", branchETag(c, rowVersion, nil))
+		respondCached(c, cacheKey, branch)
 		return
 	}
 
@@ -202,31 +592,43 @@ func getBranchBySwift(c *gin.Context) {
 		var branches []Branch
 
 		// Querry all branches under a headquarter
-		branchRows, err := db.Query(`
-		SELECT address, name, branches.country_iso2, country_name, swift_code, is_headquarter 
+		branchRows, err := db.QueryContext(ctx, `
+		SELECT address, name, branches.country_iso2, country_name, swift_code, is_headquarter, deleted_at 
 		FROM branches 
 		INNER JOIN countries ON branches.country_iso2 = countries.country_iso2 
-		WHERE swift_code LIKE ? AND swift_code NOT LIKE "%XXX"`, swiftPrefix+"%")
+		WHERE swift_code LIKE ? AND swift_code NOT LIKE "%XXX"`+deletedFilter(c), swiftPrefix+"%")
+		if storageFailure(c, err) {
+			return
+		}
 		if err != nil {
-			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to query branches under a headquarter " + branch.SWIFT_CODE})
-			log.Println(err)
+			respondError(c, http.StatusBadRequest, "Failed to query branches under a headquarter "+branch.SWIFT_CODE, err)
 			return
 		}
+		defer branchRows.Close()
 
 		for branchRows.Next() {
 			var hqBranch Branch
+			var deletedAt sql.NullTime
 			if err := branchRows.Scan(&hqBranch.ADDRESS, &hqBranch.NAME, &hqBranch.COUNTRY_ISO2_CODEID,
-				&hqBranch.COUNTRY_NAME, &hqBranch.SWIFT_CODE, &hqBranch.IS_HEADQUARTER); err != nil {
-				c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Failed to extract data from query"})
-				log.Println(err)
+				&hqBranch.COUNTRY_NAME, &hqBranch.SWIFT_CODE, &hqBranch.IS_HEADQUARTER, &deletedAt); err != nil {
+				respondError(c, http.StatusBadRequest, "Failed to extract data from query", err)
 				return
 			}
+			hqBranch.DELETED_AT = timePtr(deletedAt)
+			hqBranch.COUNTRY_DETAILS = countryDetails(hqBranch.COUNTRY_ISO2_CODEID, lang)
 			branches = append(branches, hqBranch)
 		}
+		if err := branchRows.Err(); storageFailure(c, err) {
+			return
+		} else if err != nil {
+			respondError(c, http.StatusInternalServerError, "Failed to read branches under a headquarter "+branch.SWIFT_CODE, err)
+			return
+		}
 
 		headquarter := Headquarter{branch.ADDRESS, branch.NAME, branch.COUNTRY_ISO2_CODEID,
-			branch.COUNTRY_NAME, branch.IS_HEADQUARTER, branch.SWIFT_CODE, branches}
+			branch.COUNTRY_NAME, branch.COUNTRY_DETAILS, branch.IS_HEADQUARTER, branch.SWIFT_CODE, branch.DELETED_AT, branches}
 
-		c.IndentedJSON(http.StatusOK, headquarter)
+		c.Header("ETag", branchETag(c, rowVersion, branches))
+		respondCached(c, cacheKey, headquarter)
 	}
 }
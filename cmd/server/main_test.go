@@ -1,19 +1,45 @@
 package main
 
 import (
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/cache"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
 	"Michal_Gomulczak_Assessment/SWIFT-API/internal/database"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/httpcache"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/logging"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/ratelimit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/search"
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
 )
 
+// Connect to database from DB_ environment variables, like the server does
+func connectDatabase() (*sql.DB, error) {
+	conf, err := config.Load([]string{"-import-policy", config.ImportNever})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), conf.DATABASE.CONNECT_TIMEOUT)
+	defer cancel()
+	return database.Connect(ctx, conf.DATABASE)
+}
+
 func TestGetBranchBySwift(t *testing.T) {
 	var err error
-	db, err = database.Connect()
+	db, err = connectDatabase()
 	if err != nil {
 		t.Errorf("Error connecting to database: %v", err)
 	}
@@ -119,7 +145,7 @@ func TestGetBranchBySwift(t *testing.T) {
 
 func TestGetBranchesByCountry(t *testing.T) {
 	var err error
-	db, err = database.Connect()
+	db, err = connectDatabase()
 	if err != nil {
 		t.Errorf("Error connecting to database: %v", err)
 	}
@@ -203,7 +229,7 @@ func TestGetBranchesByCountry(t *testing.T) {
 
 func TestPostBranch(t *testing.T) {
 	var err error
-	db, err = database.Connect()
+	db, err = connectDatabase()
 	if err != nil {
 		t.Errorf("Error connecting to database: %v", err)
 	}
@@ -276,7 +302,7 @@ func TestPostBranch(t *testing.T) {
 
 func TestDeleteBranch(t *testing.T) {
 	var err error
-	db, err = database.Connect()
+	db, err = connectDatabase()
 	if err != nil {
 		t.Errorf("Error connecting to database: %v", err)
 	}
@@ -354,3 +380,2245 @@ func TestDeleteBranch(t *testing.T) {
 		}
 	})
 }
+
+func TestSearchBranches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/search", searchBranches)
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+
+	searchIndex = search.NewIndex()
+	searchIndex.Add(search.Document{SWIFT_CODE: "AIZKLV22XXX", NAME: "ABLV BANK, AS IN LIQUIDATION", TOWN_NAME: "RIGA", COUNTRY_ISO2_CODEID: "LV", IS_HEADQUARTER: true})
+	searchIndex.Add(search.Document{SWIFT_CODE: "AIZKLV22CLN", NAME: "ABLV BANK, AS IN LIQUIDATION", TOWN_NAME: "RIGA", COUNTRY_ISO2_CODEID: "LV"})
+
+	// Test 1: Matching query
+	t.Run("Matching Query", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/search?q=ablv+riga&pageSize=1", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var response SearchResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if response.TOTAL != 2 || len(response.RESULTS) != 1 {
+			t.Errorf("Unexpected results: got total %v and %v results, want 2 and 1", response.TOTAL, len(response.RESULTS))
+		}
+	})
+
+	// Test 2: Missing query
+	t.Run("Missing Query", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/search", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestSuggestBranches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/suggest", suggestBranches)
+
+	searchIndex = search.NewIndex()
+	suggester = search.NewSuggester()
+	indexBranch(search.Document{SWIFT_CODE: "AIZKLV22XXX", NAME: "ABLV BANK, AS IN LIQUIDATION"})
+	indexBranch(search.Document{SWIFT_CODE: "AIZKLV22CLN", NAME: "ABLV BANK, AS IN LIQUIDATION"})
+
+	// Test 1: Matching prefix
+	t.Run("Matching Prefix", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/suggest?prefix=AIZKLV22", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var response SuggestResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if len(response.SUGGESTIONS) != 2 {
+			t.Errorf("Unexpected number of suggestions: got %v, want %v", len(response.SUGGESTIONS), 2)
+		}
+	})
+
+	// Test 2: Suggestion disappears after delete
+	t.Run("Deleted Branch", func(t *testing.T) {
+		unindexBranch("AIZKLV22CLN")
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/suggest?prefix=AIZKLV22C", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var response SuggestResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if len(response.SUGGESTIONS) != 0 {
+			t.Errorf("Expected no suggestions, but got %v", len(response.SUGGESTIONS))
+		}
+	})
+
+	// Test 3: Wrong limit
+	t.Run("Wrong Limit", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/suggest?prefix=ab&limit=0", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestCountries(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/countries", getCountries)
+	router.GET("/v1/countries/:countryISO2code", getCountry)
+	router.POST("/v1/countries", postCountry)
+	router.PUT("/v1/countries/:countryISO2code", putCountry)
+
+	columns := []string{"country_iso2", "country_name", "branch_count", "headquarter_count"}
+
+	// Test 1: List countries with counts
+	t.Run("List Countries", func(t *testing.T) {
+		mock.ExpectQuery("SELECT countries.country_iso2.*GROUP BY").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("LV", "LATVIA", 71, 20).AddRow("PL", "POLAND", 459, 120))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/countries", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var countries []CountrySummary
+		err := json.Unmarshal(resp.Body.Bytes(), &countries)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if len(countries) != 2 || countries[1].BRANCH_COUNT != 459 || countries[1].HEADQUARTER_COUNT != 120 {
+			t.Errorf("Unexpected countries: %v", countries)
+		}
+	})
+
+	// Test 1b: List failing partway is not answered with part of the countries
+	t.Run("Failed Read", func(t *testing.T) {
+		mock.ExpectQuery("SELECT countries.country_iso2.*GROUP BY").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("LV", "LATVIA", 71, 20).AddRow("PL", "POLAND", 459, 120).RowError(1, errors.New("connection reset")))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/countries", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusInternalServerError)
+		}
+	})
+
+	// Test 2: Single country, code is case insensitive
+	t.Run("Get Country", func(t *testing.T) {
+		mock.ExpectQuery("SELECT countries.country_iso2.*WHERE").WithArgs("PL").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("PL", "POLAND", 459, 120))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/countries/pl", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var country CountrySummary
+		err := json.Unmarshal(resp.Body.Bytes(), &country)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if country.COUNTRY_DETAILS == nil || country.COUNTRY_DETAILS.ALPHA3 != "POL" || country.COUNTRY_DETAILS.NAME != "Poland" {
+			t.Errorf("Unexpected country details: %v", country.COUNTRY_DETAILS)
+		}
+	})
+
+	// Test 3: Unknown country
+	t.Run("Unknown Country", func(t *testing.T) {
+		mock.ExpectQuery("SELECT countries.country_iso2.*WHERE").WithArgs("QQ").WillReturnRows(sqlmock.NewRows(columns))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/countries/QQ", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	// Test 4: Create country
+	t.Run("Create Country", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO countries").WithArgs("DE", "GERMANY").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		requestBody, _ := json.Marshal(CountryRequest{COUNTRY_ISO2_CODEID: "de", COUNTRY_NAME: "Germany"})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/countries", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 5: Create country with wrong code
+	t.Run("Wrong Country Code", func(t *testing.T) {
+		requestBody, _ := json.Marshal(CountryRequest{COUNTRY_ISO2_CODEID: "DEU", COUNTRY_NAME: "GERMANY"})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/countries", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 6: ISO names are normalized to the uppercase short name
+	t.Run("Normalize Country Name", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO countries").WithArgs("FR", "FRANCE").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		requestBody, _ := json.Marshal(CountryRequest{COUNTRY_ISO2_CODEID: "FR", COUNTRY_NAME: "French Republic"})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/countries", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 7: Rename country
+	t.Run("Update Country", func(t *testing.T) {
+		mock.ExpectQuery("SELECT countries.country_iso2.*WHERE").WithArgs("DE").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("DE", "GERMANY", 0, 0))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE countries SET country_name").WithArgs("DEUTSCHLAND", "DE").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT swift_code, name").WillReturnRows(sqlmock.NewRows([]string{"swift_code"}))
+		requestBody, _ := json.Marshal(CountryRequest{COUNTRY_NAME: "Deutschland"})
+		req, _ := http.NewRequest(http.MethodPut, "/v1/countries/DE", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 8: Country name in the requested language
+	t.Run("Localized Name", func(t *testing.T) {
+		mock.ExpectQuery("SELECT countries.country_iso2.*WHERE").WithArgs("PL").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("PL", "POLAND", 459, 120))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/countries/PL?lang=de", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var country CountrySummary
+		err := json.Unmarshal(resp.Body.Bytes(), &country)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if country.COUNTRY_DETAILS == nil || country.COUNTRY_DETAILS.LOCAL_NAME != "Polen" {
+			t.Errorf("Unexpected country details: %v", country.COUNTRY_DETAILS)
+		}
+	})
+
+	// Test 9: Unknown language
+	t.Run("Unknown Language", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/countries?lang=not-a-language", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestPostBranchCountryValidation(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/v1/swift-codes/", postBranch)
+
+	columns := []string{"country_iso2", "country_name", "branch_count", "headquarter_count"}
+	post := func(branch Branch) (*httptest.ResponseRecorder, MessageResponse) {
+		requestBody, _ := json.Marshal(branch)
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		var response MessageResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		return resp, response
+	}
+
+	// Test 1: Unknown country code
+	t.Run("Unknown Country", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("QQ").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+
+		resp, response := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "qq", COUNTRY_NAME: "NOWHERE", SWIFT_CODE: "ABCABCABCAB"})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusBadRequest)
+		}
+		expectedMessage := "Failed to insert branch: Unknown country code QQ"
+		if response.MESSAGE != expectedMessage {
+			t.Errorf("Unexpected response message: got %v want %v", response.MESSAGE, expectedMessage)
+		}
+	})
+
+	// Test 2: Country name does not match stored name
+	t.Run("Country Name Mismatch", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("PL", "POLAND", 459, 120))
+		mock.ExpectRollback()
+
+		resp, response := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "GERMANY", SWIFT_CODE: "ABCABCABCAB"})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusBadRequest)
+		}
+		expectedMessage := "Failed to insert branch: Country name GERMANY does not match POLAND for PL"
+		if response.MESSAGE != expectedMessage {
+			t.Errorf("Unexpected response message: got %v want %v", response.MESSAGE, expectedMessage)
+		}
+	})
+
+	// Test 3: Country name is compared case insensitive
+	t.Run("Country Name Case", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("PL", "POLAND", 459, 120))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*WHERE swift_code = \\? AND deleted_at IS NOT NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branches").WithArgs("abc", "ABC BANK", "PL", false, "ABCABCABCAB").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp, _ := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "Poland", SWIFT_CODE: "ABCABCABCAB"})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 4: ISO 3166 names are accepted too
+	t.Run("ISO Country Name", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("PL", "POLAND", 459, 120))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*WHERE swift_code = \\? AND deleted_at IS NOT NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branches").WithArgs("abc", "ABC BANK", "PL", false, "ABCABCABCAB").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp, _ := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "Republic of Poland", SWIFT_CODE: "ABCABCABCAB"})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 5: Unknown country is registered from ISO 3166 data when enabled
+	t.Run("Auto Register Country", func(t *testing.T) {
+		autoRegisterCountries = true
+		defer func() { autoRegisterCountries = false }()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("DE").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec("INSERT IGNORE INTO countries").WithArgs("DE", "GERMANY").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*WHERE swift_code = \\? AND deleted_at IS NOT NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branches").WithArgs("abc", "ABC BANK", "DE", false, "ABCABCABCAB").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp, _ := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "DE", COUNTRY_NAME: "Germany", SWIFT_CODE: "ABCABCABCAB"})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 6: Code has to be a SWIFT code
+	t.Run("Wrong Swift Code", func(t *testing.T) {
+		resp, response := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND", SWIFT_CODE: "ABC"})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusBadRequest)
+		}
+		expectedMessage := "Failed to insert branch: Wrong swift code ABC"
+		if response.MESSAGE != expectedMessage {
+			t.Errorf("Unexpected response message: got %v want %v", response.MESSAGE, expectedMessage)
+		}
+	})
+
+	// Test 7: Headquarter flag follows the XXX suffix, not the client
+	t.Run("Headquarter From Code", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("PL", "POLAND", 459, 120))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*WHERE swift_code = \\? AND deleted_at IS NOT NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branches").WithArgs("abc", "ABC BANK", "PL", true, "ABCAPLPWXXX").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp, _ := post(Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND", SWIFT_CODE: "ABCAPLPWXXX", IS_HEADQUARTER: false})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestBanks(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/banks", getBanks)
+	router.GET("/v1/banks/:bankCode", getBank)
+
+	// Test 1: Institution with headquarters in two countries
+	t.Run("Valid Bank", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter"}).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22XXX", true).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false).
+			AddRow("VALLETTA", "ABLV BANK MALTA", "MT", "MALTA", "AIZKMTM1XXX", true).
+			AddRow("SLIEMA", "ABLV BANK MALTA", "MT", "MALTA", "AIZKMTM2ABC", false)
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code LIKE").WithArgs("AIZK%").WillReturnRows(rows)
+		req, _ := http.NewRequest(http.MethodGet, "/v1/banks/aizk", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var bank Bank
+		err := json.Unmarshal(resp.Body.Bytes(), &bank)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if len(bank.HEADQUARTERS) != 2 || len(bank.COUNTRIES) != 2 || len(bank.NAMES) != 2 {
+			t.Errorf("Unexpected bank: %v", bank)
+		}
+		if len(bank.HEADQUARTERS[0].BRANCHES) != 1 || len(bank.LOOSE_BRANCHES) != 1 {
+			t.Errorf("Unexpected branch grouping: %v", bank)
+		}
+	})
+
+	// Test 2: Unknown institution
+	t.Run("Unknown Bank", func(t *testing.T) {
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code LIKE").WithArgs("QQQQ%").WillReturnRows(
+			sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter"}))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/banks/QQQQ", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	// Test 3: Wrong bank code
+	t.Run("Wrong Bank Code", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/banks/AIZKLV", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 4: List banks in a country
+	t.Run("List Banks", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COUNT").WithArgs("LV").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT LEFT").WithArgs("LV", 20, 0).WillReturnRows(
+			sqlmock.NewRows([]string{"bank_code", "name", "countries", "headquarters", "branches"}).AddRow("AIZK", "ABLV BANK", "LV", 1, 1))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/banks?country=lv", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var response BanksResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if response.TOTAL != 1 || len(response.BANKS) != 1 || response.BANKS[0].BRANCH_COUNT != 1 {
+			t.Errorf("Unexpected banks: %v", response)
+		}
+	})
+
+	// Test 5: Reads failing partway are not answered with partial results
+	t.Run("Failed Read", func(t *testing.T) {
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code LIKE").WithArgs("AIZK%").WillReturnRows(
+			sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter"}).
+				AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22XXX", true).
+				AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false).
+				RowError(1, errors.New("connection reset")))
+		mock.ExpectQuery("SELECT COUNT").WithArgs("LV").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery("SELECT LEFT").WithArgs("LV", 20, 0).WillReturnRows(
+			sqlmock.NewRows([]string{"bank_code", "name", "countries", "headquarters", "branches"}).
+				AddRow("AIZK", "ABLV BANK", "LV", 1, 1).
+				AddRow("PARX", "CITADELE BANKA", "LV", 1, 0).
+				RowError(1, errors.New("connection reset")))
+
+		for _, path := range []string{"/v1/banks/AIZK", "/v1/banks?country=LV"} {
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+
+			if resp.Code != http.StatusInternalServerError {
+				t.Errorf("Unexpected status code for %v: got %v, want %v", path, resp.Code, http.StatusInternalServerError)
+			}
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestLookupBranches(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.POST("/v1/swift-codes/lookup", lookupBranches)
+
+	// Test 1: Mixed found, not found and invalid codes in a single query
+	t.Run("Mixed Codes", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter"}).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22XXX", true).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false)
+		mock.ExpectQuery(`swift_code IN \(\?, \?, \?\)`).WithArgs("AIZKLV22XXX", "AIZKLV22CLN", "BREXPLPWXXX").WillReturnRows(rows)
+
+		requestBody, _ := json.Marshal(LookupRequest{[]string{"AIZKLV22", "aizklv22cln", "BREXPLPWXXX", "NOT-A-CODE", "AIZKLV22XXX"}})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/lookup", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var response LookupResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if response.FOUND != 3 || response.NOT_FOUND != 1 || response.INVALID != 1 || len(response.RESULTS) != 5 {
+			t.Errorf("Unexpected counts: %v", response)
+		}
+		wantStatus := []string{"found", "found", "notFound", "invalid", "found"}
+		for i, result := range response.RESULTS {
+			if result.STATUS != wantStatus[i] {
+				t.Errorf("Unexpected status for %v: got %v, want %v", result.QUERY, result.STATUS, wantStatus[i])
+			}
+		}
+	})
+
+	// Test 2: Empty request
+	t.Run("Empty Request", func(t *testing.T) {
+		requestBody, _ := json.Marshal(LookupRequest{})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/lookup", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 3: Read failing partway is not answered with partial results
+	t.Run("Failed Read", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter"}).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22XXX", true).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false).
+			RowError(1, errors.New("connection reset"))
+		mock.ExpectQuery(`swift_code IN \(\?, \?\)`).WithArgs("AIZKLV22XXX", "AIZKLV22CLN").WillReturnRows(rows)
+
+		requestBody, _ := json.Marshal(LookupRequest{[]string{"AIZKLV22XXX", "AIZKLV22CLN"}})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/lookup", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusInternalServerError)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestBatchBranches(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	keys, _ := auth.ParseAPIKeys("admin:admin-key:admin,steward:steward-key:steward")
+	router.POST("/v1/swift-codes/batch", auth.New(keys, nil, nil, nil).Middleware(true), batchBranches)
+
+	columns := []string{"country_iso2", "country_name", "branch_count", "headquarter_count"}
+	branchColumns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter"}
+	branch := Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND", SWIFT_CODE: "ABCABCABCAB"}
+	batch := func(request BatchRequest) (*httptest.ResponseRecorder, BatchResponse) {
+		requestBody, _ := json.Marshal(request)
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/batch", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "admin-key")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		var response BatchResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		return resp, response
+	}
+
+	// Test 1: Atomic batch commits all operations
+	t.Run("Atomic Success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(sqlmock.NewRows(columns).AddRow("PL", "POLAND", 1, 0))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*WHERE swift_code = \\? AND deleted_at IS NOT NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branches").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp, response := batch(BatchRequest{batchAtomic, []BatchOperation{
+			{OP: batchCreate, BRANCH: &branch},
+			{OP: batchDelete, SWIFT_CODE: "AIZKLV22CLN"},
+		}})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		if response.SUCCEEDED != 2 || response.FAILED != 0 {
+			t.Errorf("Unexpected results: %v", response)
+		}
+	})
+
+	// Test 2: Atomic batch rolls back when one operation fails
+	t.Run("Atomic Rollback", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("INVALIDCODE").WillReturnRows(sqlmock.NewRows(branchColumns))
+		mock.ExpectRollback()
+
+		resp, response := batch(BatchRequest{batchAtomic, []BatchOperation{
+			{OP: batchDelete, SWIFT_CODE: "AIZKLV22CLN"},
+			{OP: batchDelete, SWIFT_CODE: "INVALIDCODE"},
+		}})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+		if response.RESULTS[0].STATUS != http.StatusFailedDependency || response.RESULTS[1].STATUS != http.StatusNotFound {
+			t.Errorf("Unexpected results: %v", response.RESULTS)
+		}
+	})
+
+	// Test 3: Best effort batch applies what it can
+	t.Run("Best Effort", func(t *testing.T) {
+		// Each operation is checked and applied in its own transaction
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(sqlmock.NewRows(columns).AddRow("PL", "POLAND", 1, 0))
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("ABCABCABCAB").WillReturnRows(sqlmock.NewRows(branchColumns))
+		mock.ExpectRollback()
+
+		updated := Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND"}
+		resp, response := batch(BatchRequest{batchBestEffort, []BatchOperation{
+			{OP: batchDelete, SWIFT_CODE: "AIZKLV22CLN"},
+			{OP: batchUpdate, SWIFT_CODE: "ABCABCABCAB", BRANCH: &updated},
+			{OP: "rename", SWIFT_CODE: "ABCABCABCAB"},
+		}})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		wantStatus := []int{http.StatusOK, http.StatusNotFound, http.StatusBadRequest}
+		for i, result := range response.RESULTS {
+			if result.STATUS != wantStatus[i] {
+				t.Errorf("Unexpected status for operation %v: got %v, want %v", i, result.STATUS, wantStatus[i])
+			}
+		}
+	})
+
+	// Test 4: Wrong mode
+	t.Run("Wrong Mode", func(t *testing.T) {
+		requestBody, _ := json.Marshal(BatchRequest{"sometimes", []BatchOperation{{OP: batchDelete, SWIFT_CODE: "AIZKLV22CLN"}}})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/batch", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "admin-key")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 5: Deleting in a batch needs delete scope
+	t.Run("Missing Delete Scope", func(t *testing.T) {
+		requestBody, _ := json.Marshal(BatchRequest{batchBestEffort, []BatchOperation{{OP: batchDelete, SWIFT_CODE: "AIZKLV22CLN"}}})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/batch", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "steward-key")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusForbidden {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusForbidden)
+		}
+	})
+
+	// Test 6: Created branches need a valid code
+	t.Run("Wrong Swift Code", func(t *testing.T) {
+		wrong := Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND", SWIFT_CODE: "NOT-A-CODE"}
+		resp, response := batch(BatchRequest{batchBestEffort, []BatchOperation{{OP: batchCreate, BRANCH: &wrong}}})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		if response.RESULTS[0].STATUS != http.StatusBadRequest {
+			t.Errorf("Unexpected results: %v", response.RESULTS)
+		}
+	})
+
+	// Test 7: Country registered for a branch is rolled back with the atomic batch
+	t.Run("Atomic Rollback Registered Country", func(t *testing.T) {
+		autoRegisterCountries = true
+		defer func() { autoRegisterCountries = false }()
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("DE").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectExec("INSERT IGNORE INTO countries").WithArgs("DE", "GERMANY").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*WHERE swift_code = \\? AND deleted_at IS NOT NULL").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branches").WithArgs("abc", "ABC BANK", "DE", true, "ABCDDEFFXXX").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("INVALIDCODE").WillReturnRows(sqlmock.NewRows(branchColumns))
+		mock.ExpectRollback()
+
+		german := Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "DE", COUNTRY_NAME: "Germany", SWIFT_CODE: "ABCDDEFFXXX"}
+		resp, response := batch(BatchRequest{batchAtomic, []BatchOperation{
+			{OP: batchCreate, BRANCH: &german},
+			{OP: batchDelete, SWIFT_CODE: "INVALIDCODE"},
+		}})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+		if response.RESULTS[0].STATUS != http.StatusFailedDependency {
+			t.Errorf("Unexpected results: %v", response.RESULTS)
+		}
+	})
+
+	// Test 8: Country is checked in the batch transaction, a country removed after the request came in is reported as unknown
+	t.Run("Country Checked In Transaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("PL").WillReturnRows(sqlmock.NewRows(columns))
+		mock.ExpectRollback()
+
+		polish := Branch{ADDRESS: "abc", NAME: "ABC BANK", COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND", SWIFT_CODE: "ABCABCABCAB"}
+		resp, response := batch(BatchRequest{batchAtomic, []BatchOperation{
+			{OP: batchDelete, SWIFT_CODE: "AIZKLV22CLN"},
+			{OP: batchCreate, BRANCH: &polish},
+		}})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+		if response.RESULTS[1].MESSAGE != "Failed to insert branch: Unknown country code PL" {
+			t.Errorf("Unexpected results: %v", response.RESULTS)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+	router.POST("/v1/swift-codes/", postBranch)
+	router.POST("/v1/swift-codes/batch", batchBranches)
+	router.DELETE("/v1/swift-codes/:swift-code", deleteBranch)
+	router.POST("/v1/swift-codes/:swift-code/restore", postRestoreBranch)
+
+	searchIndex = search.NewIndex()
+	suggester = search.NewSuggester()
+	columns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter", "deleted_at", "row_version"}
+	branchColumns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter"}
+
+	// Test 1: Delete only marks the branch as deleted
+	t.Run("Delete", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at = UTC_TIMESTAMP\\(\\)").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		req, _ := http.NewRequest(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 2: Deleted branch is hidden from normal reads
+	t.Run("Hidden", func(t *testing.T) {
+		mock.ExpectQuery("WHERE branches.swift_code = \\? AND branches.deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(sqlmock.NewRows(columns))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 3: Deleted branch is returned with ?includeDeleted=true
+	t.Run("Include Deleted", func(t *testing.T) {
+		deletedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("WHERE branches.swift_code = \\?$").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false, deletedAt, 2))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN?includeDeleted=true", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var branch Branch
+		err := json.Unmarshal(resp.Body.Bytes(), &branch)
+		if err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+
+		if branch.DELETED_AT == nil || !branch.DELETED_AT.Equal(deletedAt) {
+			t.Errorf("Unexpected deletedAt: got %v, want %v", branch.DELETED_AT, deletedAt)
+		}
+	})
+
+	// Test 4: Restore deleted branch
+	t.Run("Restore", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE branches SET deleted_at = NULL").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT swift_code, name").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows([]string{"swift_code", "name", "town_name", "address", "country_iso2", "country_name", "is_headquarter"}).
+				AddRow("AIZKLV22CLN", "ABLV BANK", "RIGA", "RIGA", "LV", "LATVIA", false))
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/AIZKLV22CLN/restore", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		if _, total := searchIndex.Search("AIZKLV22CLN", "", 0, 1); total != 1 {
+			t.Errorf("Restored branch should be searchable again")
+		}
+	})
+
+	// Test 5: Nothing to restore
+	t.Run("Restore Missing", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE branches SET deleted_at = NULL").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/AIZKLV22CLN/restore", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	// Test 6: Purge removes branches deleted before the retention period
+	t.Run("Purge", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO audit_log .*SELECT").WithArgs("branch", "purge", "retention", "system", int64(86400)).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("UPDATE countries SET data_version .*WHERE country_iso2 IN").WithArgs(int64(86400)).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM branches WHERE deleted_at < UTC_TIMESTAMP\\(\\) - INTERVAL \\? SECOND").WithArgs(int64(86400)).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		purged, err := purgeDeletedBranches(context.Background(), 24*time.Hour)
+		if err != nil || purged != 3 {
+			t.Errorf("Unexpected purge result: got %v, %v", purged, err)
+		}
+	})
+
+	// Test 7: Purge loop keeps purging until its context is done
+	t.Run("Purge Loop", func(t *testing.T) {
+		purgeInterval = 10 * time.Millisecond
+		defer func() { purgeInterval = time.Hour }()
+		for i := 0; i < 2; i++ {
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO audit_log .*SELECT").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("UPDATE countries SET data_version .*WHERE country_iso2 IN").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("DELETE FROM branches WHERE deleted_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectCommit()
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			purgeLoop(ctx, 24*time.Hour)
+			close(done)
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for mock.ExpectationsWereMet() != nil && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+		<-done
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Purge should run on every tick: %v", err)
+		}
+	})
+
+	// Test 8: Adding a deleted code again replaces its row, so the row version keeps growing
+	t.Run("Add Deleted Again", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows([]string{"country_iso2", "country_name", "branch_count", "headquarter_count"}).AddRow("LV", "LATVIA", 10, 3))
+		mock.ExpectExec("UPDATE branches SET address = \\?.*row_version = row_version \\+ 1 WHERE swift_code = \\? AND deleted_at IS NOT NULL").
+			WithArgs("RIGA", "ABLV BANK", "LV", false, "AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO branch_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		requestBody, _ := json.Marshal(Branch{ADDRESS: "RIGA", NAME: "ABLV BANK", COUNTRY_ISO2_CODEID: "LV", COUNTRY_NAME: "LATVIA", SWIFT_CODE: "AIZKLV22CLN"})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/", bytes.NewBuffer(requestBody))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	keys, _ := auth.ParseAPIKeys("alice:alice-key:admin")
+	authenticator := auth.New(keys, nil, nil, nil)
+	router.DELETE("/v1/swift-codes/:swift-code", authenticator.Middleware(true), deleteBranch)
+	router.GET("/v1/audit", getAudit)
+
+	branchColumns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter"}
+	auditColumns := []string{"id", "entity", "entity_key", "action", "actor", "source", "before_value", "after_value", "created_at"}
+
+	// Test 1: Change is recorded with the authenticated actor and the previous values
+	t.Run("Record Delete", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WithArgs("branch", "AIZKLV22CLN", "delete", "alice", "api",
+			`{"address":"RIGA","bankName":"ABLV BANK","countryISO2":"LV","countryName":"LATVIA","isHeadquarter":false,"swiftCode":"AIZKLV22CLN"}`,
+			nil, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		req, _ := http.NewRequest(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", nil)
+		req.Header.Set("X-API-Key", "alice-key")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 2: Failed audit write rolls the change back
+	t.Run("Audit Failure", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnError(sql.ErrConnDone)
+		mock.ExpectRollback()
+		req, _ := http.NewRequest(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", nil)
+		req.Header.Set("X-API-Key", "alice-key")
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 3: Writes without credentials are rejected before touching the database
+	t.Run("Missing Credentials", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusUnauthorized {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusUnauthorized)
+		}
+	})
+
+	// Test 4: Query audit log by code, actor and time range
+	t.Run("Query", func(t *testing.T) {
+		from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("SELECT COUNT").WithArgs("AIZKLV22CLN", "alice", from, to).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("FROM audit_log").WithArgs("AIZKLV22CLN", "alice", from, to, 20, 0).WillReturnRows(
+			sqlmock.NewRows(auditColumns).AddRow(1, "branch", "AIZKLV22CLN", "delete", "alice", "api", []byte(`{"swiftCode":"AIZKLV22CLN"}`), nil, from))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/audit?swiftCode=aizklv22cln&actor=alice&from=2026-01-01&to=2026-02-01T00:00:00Z", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+
+		var response AuditResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if response.TOTAL != 1 || len(response.ENTRIES) != 1 || response.ENTRIES[0].ACTOR != "alice" {
+			t.Errorf("Unexpected audit response: %v", response)
+		}
+	})
+
+	// Test 5: Wrong time range
+	t.Run("Wrong Time", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/audit?from=yesterday", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestBranchHistory(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+	router.GET("/v1/swift-codes/:swift-code/history", getBranchHistory)
+	router.GET("/v1/swift-codes/country/:countryISO2code", getBranchesByCountry)
+
+	columns := []string{"version", "address", "name", "town_name", "country_iso2", "country_name", "is_headquarter", "swift_code", "valid_from", "valid_to"}
+	asOf := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	validFrom := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	validTo := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// Test 1: Branch as it was stored at a past date
+	t.Run("Branch As Of", func(t *testing.T) {
+		mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? AND valid_from").WithArgs("AIZKLV22CLN", asOf, asOf).WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(1, "OLD ADDRESS", "ABLV BANK", "RIGA", "LV", "LATVIA", false, "AIZKLV22CLN", validFrom, validTo))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN?asOf=2026-01-01", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		var branch Branch
+		if err := json.Unmarshal(resp.Body.Bytes(), &branch); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if branch.ADDRESS != "OLD ADDRESS" {
+			t.Errorf("Unexpected address: got %v, want %v", branch.ADDRESS, "OLD ADDRESS")
+		}
+	})
+
+	// Test 2: Headquarter as of a past date comes with branches valid at that time
+	t.Run("Headquarter As Of", func(t *testing.T) {
+		mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? AND valid_from").WithArgs("AIZKLV22XXX", asOf, asOf).WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(1, "RIGA", "ABLV BANK", "RIGA", "LV", "LATVIA", true, "AIZKLV22XXX", validFrom, nil))
+		mock.ExpectQuery("FROM branch_versions WHERE swift_code LIKE \\?").WithArgs("AIZKLV22%", asOf, asOf).WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(1, "OLD ADDRESS", "ABLV BANK", "RIGA", "LV", "LATVIA", false, "AIZKLV22CLN", validFrom, validTo))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22XXX?asOf=2026-01-01T00:00:00Z", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var headquarter Headquarter
+		if err := json.Unmarshal(resp.Body.Bytes(), &headquarter); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if len(headquarter.BRANCHES) != 1 {
+			t.Errorf("Unexpected branches: got %v, want 1", len(headquarter.BRANCHES))
+		}
+	})
+
+	// Test 3: Branch that did not exist yet
+	t.Run("Not Existing Yet", func(t *testing.T) {
+		mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? AND valid_from").WithArgs("AIZKLV22CLN", asOf, asOf).WillReturnRows(sqlmock.NewRows(columns))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN?asOf=2026-01-01", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	// Test 4: Wrong asOf time
+	t.Run("Wrong As Of", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN?asOf=last+year", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 5: Country listing as of a past date
+	t.Run("Country As Of", func(t *testing.T) {
+		mock.ExpectQuery("SELECT country_iso2, country_name").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows([]string{"country_iso2", "country_name", "data_version", "data_modified_at"}).AddRow("LV", "LATVIA", 4, nil))
+		mock.ExpectQuery("FROM branch_versions WHERE country_iso2 = \\?").WithArgs("LV", asOf, asOf).WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(1, "OLD ADDRESS", "ABLV BANK", "RIGA", "LV", "LATVIA", false, "AIZKLV22CLN", validFrom, validTo))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/country/LV?asOf=2026-01-01", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var country Country
+		if err := json.Unmarshal(resp.Body.Bytes(), &country); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if len(country.SWIFT_CODES) != 1 || country.SWIFT_CODES[0].ADDRESS != "OLD ADDRESS" {
+			t.Errorf("Unexpected swift codes: %v", country.SWIFT_CODES)
+		}
+	})
+
+	// Test 6: All versions of a branch
+	t.Run("History", func(t *testing.T) {
+		mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? ORDER BY version").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(columns).
+				AddRow(1, "OLD ADDRESS", "ABLV BANK", "RIGA", "LV", "LATVIA", false, "AIZKLV22CLN", validFrom, validTo).
+				AddRow(2, "RIGA", "ABLV BANK", "RIGA", "LV", "LATVIA", false, "AIZKLV22CLN", validTo, nil))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN/history", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		var response HistoryResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if len(response.VERSIONS) != 2 || response.VERSIONS[1].VALID_TO != nil {
+			t.Errorf("Unexpected versions: %v", response.VERSIONS)
+		}
+	})
+
+	// Test 7: Unknown branch has no history
+	t.Run("No History", func(t *testing.T) {
+		mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? ORDER BY version").WithArgs("INVALIDCODE").WillReturnRows(sqlmock.NewRows(columns))
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/INVALIDCODE/history", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	keys, _ := auth.ParseAPIKeys("root:root-key:admin,steward:steward-key:steward")
+	admin := router.Group("", auth.New(keys, nil, nil, nil).Middleware(true), auth.RequireScope(auth.ScopeAdmin))
+	admin.POST("/v1/admin/api-keys", postAPIKey)
+	admin.DELETE("/v1/admin/api-keys/:id", deleteAPIKey)
+
+	send := func(method, path, key string, body any) *httptest.ResponseRecorder {
+		requestBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+	keyColumns := []string{"id", "name", "prefix", "role", "scopes", "created_by", "created_at", "revoked_at"}
+
+	// Test 1: Admin creates a key, key is returned once and the creation is audited
+	t.Run("Create Key", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO api_keys").WithArgs("batch-job", sqlmock.AnyArg(), sqlmock.AnyArg(), "reader", "", "root", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(3, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WithArgs("apiKey", "3", "create", "root", "api", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp := send(http.MethodPost, "/v1/admin/api-keys", "root-key", APIKeyRequest{NAME: "batch-job", ROLE: "reader"})
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		var response APIKeyResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if response.ID != 3 || response.KEY == "" || response.ROLE != "reader" {
+			t.Errorf("Unexpected API key: %v", response)
+		}
+	})
+
+	// Test 2: Wrong role
+	t.Run("Wrong Role", func(t *testing.T) {
+		resp := send(http.MethodPost, "/v1/admin/api-keys", "root-key", APIKeyRequest{NAME: "batch-job", ROLE: "root"})
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 3: Stewards can't manage keys
+	t.Run("Missing Admin Scope", func(t *testing.T) {
+		resp := send(http.MethodPost, "/v1/admin/api-keys", "steward-key", APIKeyRequest{NAME: "batch-job", ROLE: "admin"})
+
+		if resp.Code != http.StatusForbidden {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusForbidden)
+		}
+	})
+
+	// Test 4: Revoke key
+	t.Run("Revoke Key", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM api_keys WHERE id = \\?").WithArgs(int64(3)).WillReturnRows(
+			sqlmock.NewRows(keyColumns).AddRow(3, "batch-job", "swk_abcd", "reader", "", "root", time.Now(), nil))
+		mock.ExpectExec("UPDATE api_keys SET revoked_at").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WithArgs("apiKey", "3", "delete", "root", "api", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp := send(http.MethodDelete, "/v1/admin/api-keys/3", "root-key", nil)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 5: Revoke unknown or already revoked key
+	t.Run("Revoke Missing Key", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("FROM api_keys WHERE id = \\?").WithArgs(int64(4)).WillReturnRows(sqlmock.NewRows(keyColumns))
+		mock.ExpectRollback()
+
+		resp := send(http.MethodDelete, "/v1/admin/api-keys/4", "root-key", nil)
+
+		if resp.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotFound)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	keys, _ := auth.ParseAPIKeys("alice:alice-key:reader")
+	limits := ratelimit.Limits{ROUTES: map[string]ratelimit.Limit{"GET /v1/banks": {RATE: 1, BURST: 1}}}
+	router.GET("/v1/banks", auth.New(keys, nil, nil, nil).Middleware(false), ratelimit.Middleware(limits, rateLimitClient),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/banks", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test 1: Anonymous client is limited by IP
+	t.Run("Limited By IP", func(t *testing.T) {
+		get("")
+		resp := get("")
+
+		if resp.Code != http.StatusTooManyRequests {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusTooManyRequests)
+		}
+		if resp.Header().Get("Retry-After") == "" {
+			t.Errorf("Missing Retry-After header")
+		}
+	})
+
+	// Test 2: Client with an API key has its own bucket even from the same IP
+	t.Run("Limited By Key", func(t *testing.T) {
+		resp := get("alice-key")
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		if resp.Header().Get("RateLimit-Remaining") != "0" {
+			t.Errorf("Unexpected RateLimit-Remaining: %v", resp.Header().Get("RateLimit-Remaining"))
+		}
+	})
+
+	// Test 3: Wrong keys are counted per IP before authentication
+	t.Run("Failed Authentication", func(t *testing.T) {
+		router := gin.New()
+		failures := ratelimit.FailureMiddleware(ratelimit.Limit{RATE: 1.0 / 60, BURST: 1}, http.StatusUnauthorized, func(c *gin.Context) string { return c.ClientIP() })
+		router.GET("/v1/banks", failures, auth.New(keys, nil, nil, nil).Middleware(true), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		want := map[string]int{"guess-1": http.StatusUnauthorized, "guess-2": http.StatusTooManyRequests, "alice-key": http.StatusTooManyRequests}
+		for _, key := range []string{"guess-1", "guess-2", "alice-key"} {
+			req, _ := http.NewRequest(http.MethodGet, "/v1/banks", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-API-Key", key)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			if resp.Code != want[key] {
+				t.Errorf("Unexpected status code for %v: got %v, want %v", key, resp.Code, want[key])
+			}
+		}
+	})
+}
+
+func TestResponseCache(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	responseCache = cache.NewLRU(10, time.Minute)
+	defer func() { responseCache = cache.Nop{} }()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+	router.DELETE("/v1/swift-codes/:swift-code", deleteBranch)
+
+	searchIndex = search.NewIndex()
+	suggester = search.NewSuggester()
+	columns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter", "deleted_at", "row_version"}
+	branchColumns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter"}
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test 1: First read goes to database, second one is served from cache
+	t.Run("Read Through", func(t *testing.T) {
+		mock.ExpectQuery("WHERE branches.swift_code = \\?").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false, nil, 1))
+
+		first := get("/v1/swift-codes/AIZKLV22CLN")
+		second := get("/v1/swift-codes/AIZKLV22CLN")
+
+		if first.Code != http.StatusOK || second.Code != http.StatusOK {
+			t.Errorf("Unexpected status codes: got %v and %v, want %v", first.Code, second.Code, http.StatusOK)
+		}
+		if first.Body.String() != second.Body.String() {
+			t.Errorf("Cached response differs: got %v, want %v", second.Body.String(), first.Body.String())
+		}
+		if stats := responseCache.Stats(); stats.HITS != 1 || stats.MISSES != 1 {
+			t.Errorf("Unexpected cache stats: %v", stats)
+		}
+	})
+
+	// Test 2: Requests with query parameters are not cached
+	t.Run("Query Not Cached", func(t *testing.T) {
+		mock.ExpectQuery("WHERE branches.swift_code = \\?$").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false, nil, 1))
+
+		resp := get("/v1/swift-codes/AIZKLV22CLN?includeDeleted=true")
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 3: Delete drops the cached branch
+	t.Run("Invalidate On Delete", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at = UTC_TIMESTAMP\\(\\)").WithArgs("AIZKLV22CLN").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		req, _ := http.NewRequest(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		mock.ExpectQuery("WHERE branches.swift_code = \\? AND branches.deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(sqlmock.NewRows(columns))
+		resp := get("/v1/swift-codes/AIZKLV22CLN")
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+		}
+	})
+
+	// Test 4: Headquarter whose branches fail to read partway is not cached
+	t.Run("Failed Read", func(t *testing.T) {
+		mock.ExpectQuery("WHERE branches.swift_code = \\?").WithArgs("AIZKLV22XXX").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", true, nil, 1))
+		mock.ExpectQuery("WHERE swift_code LIKE").WithArgs("AIZKLV22%").WillReturnRows(
+			sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter", "deleted_at"}).
+				AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false, nil).
+				AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22ABC", false, nil).
+				RowError(1, errors.New("connection reset")))
+		entries := responseCache.Stats().ENTRIES
+
+		resp := get("/v1/swift-codes/AIZKLV22XXX")
+
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusInternalServerError)
+		}
+		if responseCache.Stats().ENTRIES != entries {
+			t.Errorf("Partial response should not be cached")
+		}
+	})
+
+	// Test 5: Read that started before a write is not cached after the write invalidated the branch
+	t.Run("Stale Write Back", func(t *testing.T) {
+		read, _ := gin.CreateTestContext(httptest.NewRecorder())
+		read.Request, _ = http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22ABC", nil)
+		if serveCached(read, "branch:AIZKLV22ABC") {
+			t.Fatalf("Branch should not be cached yet")
+		}
+
+		invalidateBranch("AIZKLV22ABC")
+		respondCached(read, "branch:AIZKLV22ABC", Branch{SWIFT_CODE: "AIZKLV22ABC"})
+
+		if _, ok := responseCache.Get("branch:AIZKLV22ABC"); ok {
+			t.Errorf("Response read before the invalidation should not be cached")
+		}
+
+		fresh, _ := gin.CreateTestContext(httptest.NewRecorder())
+		fresh.Request, _ = http.NewRequest(http.MethodGet, "/v1/swift-codes/AIZKLV22ABC", nil)
+		serveCached(fresh, "branch:AIZKLV22ABC")
+		respondCached(fresh, "branch:AIZKLV22ABC", Branch{SWIFT_CODE: "AIZKLV22ABC"})
+
+		if _, ok := responseCache.Get("branch:AIZKLV22ABC"); !ok {
+			t.Errorf("Response read after the invalidation should be cached")
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(httpcache.CacheControl(map[string]string{"GET /v1/swift-codes/country/:countryISO2code": "no-cache"}))
+	router.GET("/v1/swift-codes/country/:countryISO2code", getBranchesByCountry)
+
+	modified := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	countryColumns := []string{"country_iso2", "country_name", "data_version", "data_modified_at"}
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/country/LV", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test 1: Response carries validators derived from the country data version
+	t.Run("Validators", func(t *testing.T) {
+		mock.ExpectQuery("SELECT country_iso2, country_name, data_version").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows(countryColumns).AddRow("LV", "LATVIA", 7, modified))
+		mock.ExpectQuery("FROM branches").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows([]string{"address", "name", "country_iso2", "is_headquarter", "swift_code", "deleted_at"}).
+				AddRow("RIGA", "ABLV BANK", "LV", false, "AIZKLV22CLN", nil))
+
+		resp := get(nil)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+		if resp.Header().Get("ETag") != `"LV-7"` {
+			t.Errorf("Unexpected ETag: %v", resp.Header().Get("ETag"))
+		}
+		if resp.Header().Get("Last-Modified") != "Fri, 02 Jan 2026 10:00:00 GMT" {
+			t.Errorf("Unexpected Last-Modified: %v", resp.Header().Get("Last-Modified"))
+		}
+		if resp.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("Unexpected Cache-Control: %v", resp.Header().Get("Cache-Control"))
+		}
+	})
+
+	// Test 2: Current ETag skips the branch query and the body
+	t.Run("If-None-Match", func(t *testing.T) {
+		mock.ExpectQuery("SELECT country_iso2, country_name, data_version").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows(countryColumns).AddRow("LV", "LATVIA", 7, modified))
+
+		resp := get(map[string]string{"If-None-Match": `"LV-7"`})
+
+		if resp.Code != http.StatusNotModified {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotModified)
+		}
+		if resp.Body.Len() != 0 {
+			t.Errorf("Unexpected body: %v", resp.Body.String())
+		}
+	})
+
+	// Test 3: Unchanged since the client's copy
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		mock.ExpectQuery("SELECT country_iso2, country_name, data_version").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows(countryColumns).AddRow("LV", "LATVIA", 7, modified))
+
+		resp := get(map[string]string{"If-Modified-Since": "Fri, 02 Jan 2026 10:00:00 GMT"})
+
+		if resp.Code != http.StatusNotModified {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusNotModified)
+		}
+	})
+
+	// Test 4: Read failing partway is neither answered nor cached with part of the branches
+	t.Run("Failed Read", func(t *testing.T) {
+		responseCache = cache.NewLRU(10, time.Minute)
+		defer func() { responseCache = cache.Nop{} }()
+		mock.ExpectQuery("SELECT country_iso2, country_name, data_version").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows(countryColumns).AddRow("LV", "LATVIA", 8, modified))
+		mock.ExpectQuery("FROM branches").WithArgs("LV").WillReturnRows(
+			sqlmock.NewRows([]string{"address", "name", "country_iso2", "is_headquarter", "swift_code", "deleted_at"}).
+				AddRow("RIGA", "ABLV BANK", "LV", false, "AIZKLV22CLN", nil).
+				AddRow("RIGA", "ABLV BANK", "LV", true, "AIZKLV22XXX", nil).
+				RowError(1, errors.New("connection reset")))
+
+		resp := get(nil)
+
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusInternalServerError)
+		}
+		if entries := responseCache.Stats().ENTRIES; entries != 0 {
+			t.Errorf("Partial response should not be cached, got %v entries", entries)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+	router.DELETE("/v1/swift-codes/:swift-code", deleteBranch)
+	router.POST("/v1/swift-codes/:swift-code/restore", postRestoreBranch)
+
+	searchIndex = search.NewIndex()
+	suggester = search.NewSuggester()
+	columns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter", "deleted_at", "row_version"}
+	branchColumns := []string{"address", "name", "country_iso2", "country_name", "is_headquarter"}
+	send := func(method, path, ifMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test 1: Row version is sent as ETag
+	t.Run("ETag", func(t *testing.T) {
+		mock.ExpectQuery("SELECT address, name.*row_version").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(columns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false, nil, 3))
+
+		resp := send(http.MethodGet, "/v1/swift-codes/AIZKLV22CLN", "")
+
+		if resp.Header().Get("ETag") != `"3"` {
+			t.Errorf("Unexpected ETag: %v", resp.Header().Get("ETag"))
+		}
+	})
+
+	// Test 1b: Responses that differ from the plain row get their own tag with the same row version
+	t.Run("ETag Variants", func(t *testing.T) {
+		hqColumns := []string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter", "deleted_at"}
+		etags := map[string]bool{}
+		for _, deleted := range []bool{false, true} {
+			path := "/v1/swift-codes/AIZKLV22XXX"
+			branches := sqlmock.NewRows(hqColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false, nil)
+			if deleted {
+				path += "?includeDeleted=true"
+				branches.AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22DEL", false, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+			}
+			mock.ExpectQuery("SELECT address, name.*row_version").WithArgs("AIZKLV22XXX").WillReturnRows(
+				sqlmock.NewRows(columns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", true, nil, 3))
+			mock.ExpectQuery("WHERE swift_code LIKE").WithArgs("AIZKLV22%").WillReturnRows(branches)
+
+			etag := send(http.MethodGet, path, "").Header().Get("ETag")
+			if !strings.HasPrefix(etag, `"3-`) {
+				t.Errorf("Unexpected ETag: %v", etag)
+			}
+			if version, ok := parseIfMatch(etag); !ok || version != 3 {
+				t.Errorf("ETag %v should match row version 3, got %v", etag, version)
+			}
+			etags[etag] = true
+		}
+		if len(etags) != 2 {
+			t.Errorf("Responses with different branches should have different ETags: %v", etags)
+		}
+	})
+
+	// Test 2: Delete of a branch someone else changed meanwhile
+	t.Run("Delete Mismatch", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at = UTC_TIMESTAMP\\(\\).*AND row_version = \\?").WithArgs("AIZKLV22CLN", int64(3)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		resp := send(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", `"3"`)
+
+		if resp.Code != http.StatusPreconditionFailed {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusPreconditionFailed)
+		}
+	})
+
+	// Test 3: Delete with the current ETag
+	t.Run("Delete Match", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT address, name.*WHERE swift_code = \\? AND deleted_at IS NULL").WithArgs("AIZKLV22CLN").WillReturnRows(
+			sqlmock.NewRows(branchColumns).AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", false))
+		mock.ExpectExec("UPDATE branches SET deleted_at = UTC_TIMESTAMP\\(\\).*AND row_version = \\?").WithArgs("AIZKLV22CLN", int64(4)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE branch_versions SET valid_to").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE countries SET data_version").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		resp := send(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", `"4"`)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 4: Restore of a deleted branch with another row version
+	t.Run("Restore Mismatch", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE branches SET deleted_at = NULL.*AND row_version = \\?").WithArgs("AIZKLV22CLN", int64(4)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM branches WHERE swift_code = \\? AND deleted_at IS NOT NULL").WithArgs("AIZKLV22CLN").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		resp := send(http.MethodPost, "/v1/swift-codes/AIZKLV22CLN/restore", `"4"`)
+
+		if resp.Code != http.StatusPreconditionFailed {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusPreconditionFailed)
+		}
+	})
+
+	// Test 5: Weak ETags never match
+	t.Run("Weak ETag", func(t *testing.T) {
+		resp := send(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", `W/"5"`)
+
+		if resp.Code != http.StatusPreconditionFailed {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusPreconditionFailed)
+		}
+	})
+
+	// Test 6: If-Match can be made mandatory
+	t.Run("Required", func(t *testing.T) {
+		requireIfMatch = true
+		defer func() { requireIfMatch = false }()
+
+		resp := send(http.MethodDelete, "/v1/swift-codes/AIZKLV22CLN", "")
+
+		if resp.Code != http.StatusPreconditionRequired {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusPreconditionRequired)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestQueryTimeouts(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+	router.POST("/v1/swift-codes/lookup", lookupBranches)
+	router.POST("/v1/swift-codes/batch", batchBranches)
+
+	// Test 1: Slow query runs out of read time, MySQL driver returns the context error then
+	t.Run("Read Timeout", func(t *testing.T) {
+		mock.ExpectQuery("SELECT address, name").WithArgs("TIMEOUT1XXX").WillReturnError(context.DeadlineExceeded)
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/TIMEOUT1XXX", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusGatewayTimeout {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusGatewayTimeout)
+		}
+	})
+
+	// Test 2: Broken connection
+	t.Run("Database Unavailable", func(t *testing.T) {
+		mock.ExpectQuery("SELECT address, name").WithArgs("BROKENCOXXX").WillReturnError(mysql.ErrInvalidConn)
+		req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/BROKENCOXXX", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusServiceUnavailable {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusServiceUnavailable)
+		}
+	})
+
+	// Test 3: Rows stop in the middle of a result
+	t.Run("Timeout While Reading Rows", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"address", "name", "country_iso2", "country_name", "swift_code", "is_headquarter"}).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22XXX", true).
+			AddRow("RIGA", "ABLV BANK", "LV", "LATVIA", "AIZKLV22CLN", false).
+			RowError(1, context.DeadlineExceeded)
+		mock.ExpectQuery("SELECT address, name.*swift_code IN").WillReturnRows(rows)
+		body, _ := json.Marshal(LookupRequest{[]string{"AIZKLV22XXX", "AIZKLV22CLN"}})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/lookup", bytes.NewBuffer(body))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusGatewayTimeout {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusGatewayTimeout)
+		}
+	})
+
+	// Test 4: Atomic batch answers with the timeout of its failed operation
+	t.Run("Batch Timeout", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT countries.country_iso2").WithArgs("LV").WillReturnError(context.DeadlineExceeded)
+		mock.ExpectRollback()
+		body, _ := json.Marshal(BatchRequest{OPERATIONS: []BatchOperation{{OP: batchCreate, BRANCH: &Branch{ADDRESS: "RIGA",
+			NAME: "ABLV BANK", COUNTRY_ISO2_CODEID: "LV", COUNTRY_NAME: "LATVIA", SWIFT_CODE: "AIZKLV22NEW"}}}})
+		req, _ := http.NewRequest(http.MethodPost, "/v1/swift-codes/batch", bytes.NewBuffer(body))
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusGatewayTimeout {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusGatewayTimeout)
+		}
+		var response BatchResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if response.RESULTS[0].STATUS != http.StatusGatewayTimeout {
+			t.Errorf("Unexpected result: %v", response.RESULTS[0])
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Start serving a handler that waits for release, returns the server's address and its result
+	start := func(t *testing.T, ctx context.Context, drain time.Duration, started, release chan struct{}) (string, chan error) {
+		router := gin.New()
+		router.GET("/slow", func(c *gin.Context) {
+			close(started)
+			<-release
+			c.IndentedJSON(http.StatusOK, gin.H{"message": "done"})
+		})
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- serve(ctx, &http.Server{Handler: router}, listener, drain)
+		}()
+		return "http://" + listener.Addr().String(), done
+	}
+
+	// Test 1: In-flight request finishes before the server stops
+	t.Run("Drain", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		started, release := make(chan struct{}), make(chan struct{})
+		address, done := start(t, ctx, 5*time.Second, started, release)
+
+		responses := make(chan int, 1)
+		go func() {
+			resp, err := http.Get(address + "/slow")
+			if err != nil {
+				responses <- 0
+				return
+			}
+			resp.Body.Close()
+			responses <- resp.StatusCode
+		}()
+		<-started
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		if _, err := http.Get(address + "/slow"); err == nil {
+			t.Errorf("Server should not accept new connections while draining")
+		}
+		close(release)
+
+		if code := <-responses; code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", code, http.StatusOK)
+		}
+		if err := <-done; err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	// Test 2: Requests still running after the drain timeout are cut off
+	t.Run("Drain Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		started, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		address, done := start(t, ctx, 50*time.Millisecond, started, release)
+
+		go func() {
+			if resp, err := http.Get(address + "/slow"); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-started
+		cancel()
+
+		if err := <-done; err == nil {
+			t.Errorf("Expected an error for requests cut off by the drain timeout")
+		}
+	})
+
+	// Test 3: Startup import holds the import flag from the start until it returned
+	t.Run("Startup Import", func(t *testing.T) {
+		var mock sqlmock.Sqlmock
+		var err error
+		db, mock, err = sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+		defer db.Close()
+		defer startupFinished.Store(nil)
+		searchIndex = search.NewIndex()
+		suggester = search.NewSuggester()
+		mock.ExpectQuery("SELECT swift_code, name").WillDelayFor(50 * time.Millisecond).WillReturnRows(
+			sqlmock.NewRows([]string{"swift_code", "name", "town_name", "address", "country_iso2", "country_name", "is_headquarter"}))
+
+		done, ok := startLoadData(config.ImportNever)
+		if !ok {
+			t.Fatalf("Startup import should take the import flag")
+		}
+		router := gin.New()
+		router.POST("/v1/admin/import", postImport)
+		req, _ := http.NewRequest(http.MethodPost, "/v1/admin/import", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusConflict {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusConflict)
+		}
+		if _, ok := startLoadData(config.ImportNever); ok {
+			t.Errorf("Second import should not start while the first runs")
+		}
+		<-done
+		if importRunning.Load() {
+			t.Errorf("Import flag should be released after the import")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Mock expectations were not met: %v", err)
+		}
+	})
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	dbMonitor = database.NewMonitor(db, time.Minute, database.Retry{INITIAL: time.Second, MAX: time.Second})
+	defer startupFinished.Store(nil)
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/healthz", getHealthz)
+	router.GET("/readyz", getReadyz)
+
+	readyz := func(t *testing.T, version int, code int) Readiness {
+		mock.ExpectQuery("SELECT COALESCE\\(MAX\\(version\\), 0\\) FROM schema_version").
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+		req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != code {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, code)
+		}
+		var readiness Readiness
+		if err := json.Unmarshal(resp.Body.Bytes(), &readiness); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		return readiness
+	}
+
+	// Test 1: Liveness doesn't touch the database
+	t.Run("Liveness", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+		}
+	})
+
+	// Test 2: Not ready while the startup import runs
+	t.Run("Import Running", func(t *testing.T) {
+		readiness := readyz(t, database.SchemaVersion(), http.StatusServiceUnavailable)
+		if readiness.READY || readiness.COMPONENTS.IMPORT.UP || !readiness.COMPONENTS.SCHEMA.UP || !readiness.COMPONENTS.DATABASE.UP {
+			t.Errorf("Unexpected readiness: %+v", readiness)
+		}
+	})
+
+	// Test 3: Ready once the import finished
+	t.Run("Ready", func(t *testing.T) {
+		finished := time.Now()
+		startupFinished.Store(&finished)
+		readiness := readyz(t, database.SchemaVersion(), http.StatusOK)
+		if !readiness.READY || readiness.COMPONENTS.IMPORT.FINISHED == nil {
+			t.Errorf("Unexpected readiness: %+v", readiness)
+		}
+	})
+
+	// Test 4: Schema behind the server's migrations
+	t.Run("Old Schema", func(t *testing.T) {
+		readiness := readyz(t, database.SchemaVersion()-1, http.StatusServiceUnavailable)
+		if readiness.COMPONENTS.SCHEMA.UP || readiness.COMPONENTS.SCHEMA.EXPECTED != database.SchemaVersion() {
+			t.Errorf("Unexpected schema status: %+v", readiness.COMPONENTS.SCHEMA)
+		}
+	})
+
+	// Test 5: Schema version can't be read
+	t.Run("Schema Error", func(t *testing.T) {
+		mock.ExpectQuery("SELECT COALESCE").WillReturnError(mysql.ErrInvalidConn)
+		req, _ := http.NewRequest(http.MethodGet, "/readyz", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusServiceUnavailable {
+			t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusServiceUnavailable)
+		}
+
+		var readiness Readiness
+		if err := json.Unmarshal(resp.Body.Bytes(), &readiness); err != nil {
+			t.Fatalf("Could not decode response: %v", err)
+		}
+		if readiness.COMPONENTS.SCHEMA.ERROR != "Failed to read schema version" {
+			t.Errorf("Driver error should not be sent: %v", readiness.COMPONENTS.SCHEMA.ERROR)
+		}
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestRequestIDInErrors(t *testing.T) {
+	var mock sqlmock.Sqlmock
+	var err error
+	db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.RequestIDs())
+	router.GET("/v1/swift-codes/:swift-code", getBranchBySwift)
+
+	mock.ExpectQuery("SELECT address, name").WithArgs("MISSINGAXXX").WillReturnError(sql.ErrNoRows)
+	req, _ := http.NewRequest(http.MethodGet, "/v1/swift-codes/MISSINGAXXX", nil)
+	req.Header.Set(logging.RequestIDHeader, "support-ticket-7")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Unexpected status code: got %v, want %v", resp.Code, http.StatusBadRequest)
+	}
+	var response map[string]string
+	if err := json.Unmarshal(resp.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if response["requestId"] != "support-ticket-7" || resp.Header().Get(logging.RequestIDHeader) != "support-ticket-7" {
+		t.Errorf("Request id missing from error response: %v", response)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
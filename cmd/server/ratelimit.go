@@ -0,0 +1,44 @@
+package main
+
+import (
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build limits from configuration, default limit "off" disables it
+func loadRateLimits(conf config.RateLimit) (ratelimit.Limits, error) {
+	var limits ratelimit.Limits
+	if conf.DEFAULT != "off" {
+		limit, err := ratelimit.ParseLimit(conf.DEFAULT)
+		if err != nil {
+			return limits, err
+		}
+		limits.DEFAULT = limit
+	}
+
+	routes, err := ratelimit.ParseRoutes(conf.ROUTES)
+	if err != nil {
+		return limits, err
+	}
+	limits.ROUTES = routes
+	return limits, nil
+}
+
+// Build limit of failed authentications per IP, "off" disables it
+func loadFailureLimit(conf config.RateLimit) (ratelimit.Limit, error) {
+	if conf.AUTH_FAILURES == "off" {
+		return ratelimit.Limit{}, nil
+	}
+	return ratelimit.ParseLimit(conf.AUTH_FAILURES)
+}
+
+// Authenticated clients are limited per credential name, anonymous ones per IP
+func rateLimitClient(c *gin.Context) string {
+	if principal, ok := auth.PrincipalOf(c); ok {
+		return "principal:" + principal.NAME
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/search"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/textnorm"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+)
+
+type SearchResponse struct {
+	QUERY     string       `json:"query"`
+	TOTAL     int          `json:"total"`
+	PAGE      int          `json:"page"`
+	PAGE_SIZE int          `json:"pageSize"`
+	RESULTS   []search.Hit `json:"results"`
+}
+
+type SuggestResponse struct {
+	PREFIX      string              `json:"prefix"`
+	SUGGESTIONS []search.Suggestion `json:"suggestions"`
+}
+
+var (
+	searchIndex = search.NewIndex()
+	suggester   = search.NewSuggester()
+)
+
+// Rebuild search index and suggester from database
+func loadSearchIndexes(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "rebuild search indexes")
+	defer func() { tracing.End(span, err) }()
+
+	docs, err := search.LoadDocuments(ctx, db)
+	if err != nil {
+		return err
+	}
+	searchIndex.Reset(docs)
+	suggester.Reset(docs)
+	return nil
+}
+
+// Keep search index and suggester in sync after a branch was written
+func indexBranch(doc search.Document) {
+	searchIndex.Add(doc)
+	suggester.Add(doc)
+}
+
+// Keep search index and suggester in sync after a branch was deleted
+func unindexBranch(swift string) {
+	searchIndex.Remove(swift)
+	suggester.Remove(swift)
+}
+
+func searchBranches(c *gin.Context) {
+	query := c.Query("q")
+	if len(textnorm.Tokenize(query)) == 0 {
+		respondError(c, http.StatusBadRequest, "Missing search query, use ?q=", nil)
+		return
+	}
+
+	page, pageSize, ok := parsePagination(c)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "Wrong pagination, page and pageSize must be positive numbers", nil)
+		return
+	}
+
+	hits, total := searchIndex.Search(query, c.Query("country"), (page-1)*pageSize, pageSize)
+	if hits == nil {
+		hits = []search.Hit{}
+	}
+
+	c.IndentedJSON(http.StatusOK, SearchResponse{query, total, page, pageSize, hits})
+}
+
+func suggestBranches(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if len(textnorm.Tokenize(prefix)) == 0 {
+		respondError(c, http.StatusBadRequest, "Missing prefix, use ?prefix=", nil)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSuggestLimit)))
+	if err != nil || limit < 1 {
+		respondError(c, http.StatusBadRequest, "Wrong limit, must be a positive number", nil)
+		return
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	c.IndentedJSON(http.StatusOK, SuggestResponse{prefix, suggester.Suggest(prefix, limit)})
+}
+
+// Read ?page= and ?pageSize= query params, page size is capped at maxPageSize
+func parsePagination(c *gin.Context) (int, int, bool) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, false
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(defaultPageSize)))
+	if err != nil || pageSize < 1 {
+		return 0, 0, false
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize, true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Longest database work of a request or background job, main sets them from configuration
+var (
+	readTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second
+	bulkTimeout  = 30 * time.Minute
+)
+
+// Context of the storage calls of a read request, ends when the client goes away or after readTimeout
+func readContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request.Context(), readTimeout)
+}
+
+// Same for a write request, a write cut short is rolled back with its transaction
+func writeContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request.Context(), writeTimeout)
+}
+
+// Context of imports, search index rebuilds and purges, they don't end with the request that started them
+// but are canceled when shutdown starts
+func bulkContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(lifecycle, bulkTimeout)
+}
+
+// Status and message for storage errors that are not the client's fault, false for other errors.
+// 504 when the database did not answer in time, 503 when it can't be reached or the request was canceled
+func storageStatus(err error) (int, string, bool) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return http.StatusGatewayTimeout, "Database did not answer in time, try again later", true
+	case errors.Is(err, context.Canceled), errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), netErr != nil:
+		return http.StatusServiceUnavailable, "Database is unavailable, try again later", true
+	}
+	return 0, "", false
+}
+
+// Write the response for a storage error that is not the client's fault, returns false for other errors
+func storageFailure(c *gin.Context, err error) bool {
+	status, message, ok := storageStatus(err)
+	if !ok {
+		return false
+	}
+	respondError(c, status, message, err)
+	return true
+}
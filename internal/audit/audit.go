@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Where a change came from
+const (
+	SourceAPI    = "api"
+	SourceImport = "import"
+	SourceSystem = "system"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+const (
+	EntityBranch  = "branch"
+	EntityCountry = "country"
+	EntityAPIKey  = "apiKey"
+)
+
+type Entry struct {
+	ID         int64           `json:"id"`
+	ENTITY     string          `json:"entity"`
+	KEY        string          `json:"key"`
+	ACTION     string          `json:"action"`
+	ACTOR      string          `json:"actor"`
+	SOURCE     string          `json:"source"`
+	BEFORE     json.RawMessage `json:"before,omitempty"`
+	AFTER      json.RawMessage `json:"after,omitempty"`
+	CREATED_AT time.Time       `json:"createdAt"`
+}
+
+// Zero values are not filtered on
+type Filter struct {
+	KEY   string
+	ACTOR string
+	FROM  time.Time
+	TO    time.Time
+}
+
+// Both *sql.DB and *sql.Tx, entry written in a transaction is rolled back with the change
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Write a single entry, creation time defaults to now
+func Record(ctx context.Context, ex Execer, entry Entry) error {
+	if entry.CREATED_AT.IsZero() {
+		entry.CREATED_AT = time.Now().UTC()
+	}
+	query := `INSERT INTO audit_log (entity, entity_key, action, actor, source, before_value, after_value, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := ex.ExecContext(ctx, query, entry.ENTITY, entry.KEY, entry.ACTION, entry.ACTOR, entry.SOURCE,
+		nullJSON(entry.BEFORE), nullJSON(entry.AFTER), entry.CREATED_AT)
+	return err
+}
+
+// JSON value of a record for before and after fields, nil values stay empty
+func Snapshot(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+// Entries matching filter, newest first, with total count for pagination
+func Query(ctx context.Context, db *sql.DB, filter Filter, offset, limit int) ([]Entry, int, error) {
+	where := ` WHERE 1 = 1`
+	var args []any
+	if filter.KEY != "" {
+		where += ` AND entity_key = ?`
+		args = append(args, filter.KEY)
+	}
+	if filter.ACTOR != "" {
+		where += ` AND actor = ?`
+		args = append(args, filter.ACTOR)
+	}
+	if !filter.FROM.IsZero() {
+		where += ` AND created_at >= ?`
+		args = append(args, filter.FROM.UTC())
+	}
+	if !filter.TO.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, filter.TO.UTC())
+	}
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+	SELECT id, entity, entity_key, action, actor, source, before_value, after_value, created_at
+	FROM audit_log`+where+`
+	ORDER BY id DESC
+	LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.ENTITY, &entry.KEY, &entry.ACTION, &entry.ACTOR, &entry.SOURCE,
+			&before, &after, &entry.CREATED_AT); err != nil {
+			return nil, 0, err
+		}
+		entry.BEFORE, entry.AFTER = before, after
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+func nullJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("^INSERT INTO audit_log.*").
+		WithArgs(EntityBranch, "ABCABCABCAB", ActionCreate, "alice", SourceAPI, nil, `{"swiftCode":"ABCABCABCAB"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = Record(context.Background(), db, Entry{
+		ENTITY: EntityBranch,
+		KEY:    "ABCABCABCAB",
+		ACTION: ActionCreate,
+		ACTOR:  "alice",
+		SOURCE: SourceAPI,
+		AFTER:  Snapshot(map[string]string{"swiftCode": "ABCABCABCAB"}),
+	})
+	assert.NoError(t, err, "Record should not return an error")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestSnapshotNil(t *testing.T) {
+	var record *struct{ NAME string }
+	assert.Nil(t, Snapshot(nil), "Nil value should have no snapshot")
+	assert.Nil(t, Snapshot(record), "Nil pointer should have no snapshot")
+}
+
+func TestQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM audit_log WHERE 1 = 1 AND actor = \\? AND created_at >= \\?").
+		WithArgs("alice", from).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("FROM audit_log WHERE 1 = 1 AND actor = \\? AND created_at >= \\?").
+		WithArgs("alice", from, 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "entity", "entity_key", "action", "actor", "source", "before_value", "after_value", "created_at"}).
+			AddRow(7, EntityBranch, "ABCABCABCAB", ActionDelete, "alice", SourceAPI, []byte(`{"swiftCode":"ABCABCABCAB"}`), nil, created))
+
+	entries, total, err := Query(context.Background(), db, Filter{ACTOR: "alice", FROM: from}, 0, 20)
+	assert.NoError(t, err, "Query should not return an error")
+	assert.Equal(t, 1, total)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, ActionDelete, entries[0].ACTION)
+	assert.JSONEq(t, `{"swiftCode":"ABCABCABCAB"}`, string(entries[0].BEFORE))
+	assert.Nil(t, entries[0].AFTER)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	MethodAPIKey = "apiKey"
+	MethodJWT    = "jwt"
+)
+
+// Gin context key of the authenticated principal
+const principalKey = "auth.principal"
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Who sent the request and what they may do
+type Principal struct {
+	NAME   string   `json:"name"`
+	METHOD string   `json:"method"`
+	ROLE   string   `json:"role"`
+	SCOPES []string `json:"scopes"`
+}
+
+// Checks API keys sent in X-API-Key header and JWTs sent as Authorization: Bearer token
+type Authenticator struct {
+	apiKeys    map[[sha256.Size]byte]Principal
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	store      *KeyStore
+	issuer     string
+	audience   string
+}
+
+// API keys map configured keys to their principal, keys created through the API are looked up in store.
+// JWTs are accepted for every configured key and need a sub claim. Nil store disables stored keys.
+func New(apiKeys map[string]Principal, hmacSecret []byte, rsaKey *rsa.PublicKey, store *KeyStore) *Authenticator {
+	// Only hashes are kept, lookup by hash does not leak key prefixes through timing
+	hashed := make(map[[sha256.Size]byte]Principal, len(apiKeys))
+	for key, principal := range apiKeys {
+		hashed[sha256.Sum256([]byte(key))] = principal
+	}
+	return &Authenticator{apiKeys: hashed, hmacSecret: hmacSecret, rsaKey: rsaKey, store: store}
+}
+
+// Require iss and aud claims of JWTs, so tokens signed with the same key for other services are refused.
+// Empty values are not checked
+func (a *Authenticator) ExpectClaims(issuer, audience string) {
+	a.issuer, a.audience = issuer, audience
+}
+
+// True if any credentials can be accepted at all
+func (a *Authenticator) Enabled() bool {
+	return len(a.apiKeys) > 0 || len(a.hmacSecret) > 0 || a.rsaKey != nil || a.store != nil
+}
+
+// Returns ErrNoCredentials or ErrInvalidCredentials when request can't be authenticated,
+// other errors mean the key store could not be checked
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		if principal, ok := a.apiKeys[sha256.Sum256([]byte(key))]; ok {
+			return principal, nil
+		}
+		if a.store == nil {
+			return Principal{}, ErrInvalidCredentials
+		}
+		principal, err := a.store.Lookup(r.Context(), key)
+		if err == sql.ErrNoRows {
+			return Principal{}, ErrInvalidCredentials
+		}
+		return principal, err
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return a.verifyToken(strings.TrimSpace(token))
+}
+
+func (a *Authenticator) verifyToken(token string) (Principal, error) {
+	var methods []string
+	if len(a.hmacSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if a.rsaKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	// Tokens without exp would be valid forever
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if a.issuer != "" {
+		options = append(options, jwt.WithIssuer(a.issuer))
+	}
+	if a.audience != "" {
+		options = append(options, jwt.WithAudience(a.audience))
+	}
+
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		// Algorithm is checked by WithValidMethods, key has to match it
+		if t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return a.hmacSecret, nil
+		}
+		return a.rsaKey, nil
+	}, options...)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if claims.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: missing sub claim", ErrInvalidCredentials)
+	}
+
+	// Tokens without role claim are read only
+	role := claims.Role
+	if role == "" {
+		role = RoleReader
+	}
+	if !ValidRole(role) {
+		return Principal{}, fmt.Errorf("%w: unknown role %s", ErrInvalidCredentials, role)
+	}
+	return newPrincipal(claims.Subject, MethodJWT, role, strings.Fields(claims.Scope)), nil
+}
+
+// Registered claims with the role and OAuth style space separated scope claims
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Role  string `json:"role"`
+	Scope string `json:"scope"`
+}
+
+// Gin middleware storing the principal in context. Wrong credentials are always rejected,
+// missing credentials only when required.
+func (a *Authenticator) Middleware(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := a.Authenticate(c.Request)
+		if err == ErrNoCredentials && !required {
+			c.Next()
+			return
+		}
+		if err != nil && err != ErrNoCredentials && !errors.Is(err, ErrInvalidCredentials) {
+			status := http.StatusInternalServerError
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			slog.ErrorContext(c.Request.Context(), "Failed to check credentials", "error", err)
+			c.AbortWithStatusJSON(status, gin.H{"message": "Failed to check credentials", "requestId": logging.RequestID(c.Request.Context())})
+			return
+		}
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="swift-api"`)
+			message := "Invalid credentials"
+			if err == ErrNoCredentials {
+				message = "Missing credentials, use X-API-Key header or Authorization: Bearer token"
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": message, "requestId": logging.RequestID(c.Request.Context())})
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// Principal set by Middleware, false for anonymous requests
+func PrincipalOf(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// Parse API keys in name:key:role,name:key:role format, each name can be used once
+func ParseAPIKeys(value string) (map[string]Principal, error) {
+	keys := map[string]Principal{}
+	names := map[string]bool{}
+	for i, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		// Entry is not printed, it may be a bare key
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("wrong API key entry %d, use name:key:role", i+1)
+		}
+		if !ValidRole(parts[2]) {
+			return nil, fmt.Errorf("wrong role in API key entry %d, use reader, steward or admin", i+1)
+		}
+		// Name is the audit actor and the rate limit bucket, two keys under one name could not be told apart
+		if names[parts[0]] {
+			return nil, fmt.Errorf("API key name %q is used twice", parts[0])
+		}
+		names[parts[0]] = true
+		keys[parts[1]] = newPrincipal(parts[0], MethodAPIKey, parts[2], nil)
+	}
+	return keys, nil
+}
+
+func ParseRSAPublicKey(pem []byte) (*rsa.PublicKey, error) {
+	return jwt.ParseRSAPublicKeyFromPEM(pem)
+}
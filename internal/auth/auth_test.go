@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func request(header, value string) *http.Request {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+	return req
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func keys(t *testing.T, value string) map[string]Principal {
+	keys, err := ParseAPIKeys(value)
+	if err != nil {
+		t.Fatalf("Failed to parse API keys: %v", err)
+	}
+	return keys
+}
+
+func TestAPIKey(t *testing.T) {
+	a := New(keys(t, "batch-job:secret-key:reader"), nil, nil, nil)
+
+	principal, err := a.Authenticate(request("X-API-Key", "secret-key"))
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{"batch-job", MethodAPIKey, RoleReader, []string{ScopeRead}}, principal)
+
+	_, err = a.Authenticate(request("X-API-Key", "other-key"))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(request("", ""))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestHS256(t *testing.T) {
+	secret := []byte("hmac-secret")
+	a := New(nil, secret, nil, nil)
+	hour := time.Now().Add(time.Hour).Unix()
+
+	token := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": hour})
+	principal, err := a.Authenticate(request("Authorization", "Bearer "+token))
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{"alice", MethodJWT, RoleReader, []string{ScopeRead}}, principal, "Token without role is read only")
+
+	steward := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "role": "steward", "scope": "delete unknown", "exp": hour})
+	principal, err = a.Authenticate(request("Authorization", "Bearer "+steward))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ScopeRead, ScopeWrite, ScopeDelete}, principal.SCOPES, "Scope claim adds known scopes to role")
+
+	superuser := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "role": "root", "exp": hour})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+superuser))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Unknown role should be rejected")
+
+	expired := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+expired))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Expired token should be rejected")
+
+	forged := sign(t, jwt.SigningMethodHS256, []byte("other-secret"), jwt.MapClaims{"sub": "alice", "exp": hour})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+forged))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Token signed with another secret should be rejected")
+
+	anonymous := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"exp": hour})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+anonymous))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Token without subject should be rejected")
+
+	forever := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice"})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+forever))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Token without expiry should be rejected")
+}
+
+func TestExpectClaims(t *testing.T) {
+	secret := []byte("hmac-secret")
+	a := New(nil, secret, nil, nil)
+	a.ExpectClaims("https://login.example.com", "swift-api")
+	hour := time.Now().Add(time.Hour).Unix()
+
+	token := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": hour, "iss": "https://login.example.com", "aud": "swift-api"})
+	_, err := a.Authenticate(request("Authorization", "Bearer "+token))
+	assert.NoError(t, err)
+
+	other := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": hour, "iss": "https://login.example.com", "aud": "billing"})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+other))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Token for another audience should be rejected")
+
+	unknown := sign(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": hour, "aud": "swift-api"})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+unknown))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Token without issuer should be rejected")
+}
+
+func TestRS256(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	public, err := ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Failed to parse public key: %v", err)
+	}
+	a := New(nil, nil, public, nil)
+	hour := time.Now().Add(time.Hour).Unix()
+
+	token := sign(t, jwt.SigningMethodRS256, private, jwt.MapClaims{"sub": "steward", "exp": hour})
+	principal, err := a.Authenticate(request("Authorization", "Bearer "+token))
+	assert.NoError(t, err)
+	assert.Equal(t, "steward", principal.NAME)
+
+	// HS256 token must not be verified with the public key as HMAC secret
+	confused := sign(t, jwt.SigningMethodHS256, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), jwt.MapClaims{"sub": "steward", "exp": hour})
+	_, err = a.Authenticate(request("Authorization", "Bearer "+confused))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := New(keys(t, "batch-job:secret-key:admin"), nil, nil, nil)
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		principal, _ := PrincipalOf(c)
+		c.String(http.StatusOK, principal.NAME)
+	}
+	router.GET("/public", a.Middleware(false), handler)
+	router.GET("/private", a.Middleware(true), handler)
+
+	cases := []struct {
+		path   string
+		key    string
+		status int
+		body   string
+	}{
+		{"/public", "", http.StatusOK, ""},
+		{"/public", "secret-key", http.StatusOK, "batch-job"},
+		{"/public", "other-key", http.StatusUnauthorized, ""},
+		{"/private", "", http.StatusUnauthorized, ""},
+		{"/private", "secret-key", http.StatusOK, "batch-job"},
+	}
+	for _, tc := range cases {
+		req := request("", "")
+		req.URL.Path = tc.path
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tc.status, resp.Code, "%s with key %q", tc.path, tc.key)
+		if tc.status == http.StatusOK {
+			assert.Equal(t, tc.body, resp.Body.String())
+		} else {
+			assert.NotEmpty(t, resp.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	parsed, err := ParseAPIKeys("alice:key1:admin, bob:key2:steward,")
+	assert.NoError(t, err)
+	assert.Len(t, parsed, 2)
+	assert.Equal(t, RoleAdmin, parsed["key1"].ROLE)
+	assert.Equal(t, RoleSteward, parsed["key2"].ROLE)
+
+	_, err = ParseAPIKeys("alice:key1")
+	assert.Error(t, err, "Configured key without role should be rejected")
+	assert.NotContains(t, err.Error(), "key1", "Error should not leak the key")
+
+	_, err = ParseAPIKeys("alice:key1:admin,alice:key2:reader")
+	assert.ErrorContains(t, err, `"alice"`, "Two keys under one name should be rejected")
+	assert.NotContains(t, err.Error(), "key2", "Error should not leak the key")
+
+	_, err = ParseAPIKeys("just-a-key")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "just-a-key", "Error should not leak the key")
+
+	_, err = ParseAPIKeys("alice:key1:root")
+	assert.Error(t, err)
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := New(keys(t, "reader:reader-key:reader,steward:steward-key:steward"), nil, nil, nil)
+	router := gin.New()
+	router.POST("/branches", a.Middleware(false), RequireScope(ScopeWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	cases := []struct {
+		key    string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"reader-key", http.StatusForbidden},
+		{"steward-key", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, "/branches", nil)
+		if tc.key != "" {
+			req.Header.Set("X-API-Key", tc.key)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tc.status, resp.Code, "key %q", tc.key)
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Prefix of generated keys, makes leaked keys easy to find by secret scanners
+const keyPrefix = "swk_"
+
+// API key as stored in database, the key itself is only known at creation
+type APIKey struct {
+	ID         int64      `json:"id"`
+	NAME       string     `json:"name"`
+	PREFIX     string     `json:"prefix"`
+	ROLE       string     `json:"role"`
+	SCOPES     []string   `json:"scopes"`
+	CREATED_BY string     `json:"createdBy"`
+	CREATED_AT time.Time  `json:"createdAt"`
+	REVOKED_AT *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Both *sql.DB and *sql.Tx, so key changes can be written together with their audit entry
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// API keys stored hashed in api_keys table, lookups give up after timeout unless it is 0
+type KeyStore struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewKeyStore(db *sql.DB, timeout time.Duration) *KeyStore {
+	return &KeyStore{db, timeout}
+}
+
+// Principal of a key that is not revoked, returns sql.ErrNoRows for unknown keys
+func (s *KeyStore) Lookup(ctx context.Context, key string) (Principal, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	var name, role, scopes string
+	err := s.db.QueryRowContext(ctx, `SELECT name, role, scopes FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`, HashKey(key)).
+		Scan(&name, &role, &scopes)
+	if err != nil {
+		return Principal{}, err
+	}
+	return newPrincipal(name, MethodAPIKey, role, splitScopes(scopes)), nil
+}
+
+// All keys, newest first
+func (s *KeyStore) List(ctx context.Context) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, keyQuery+` ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Generate and store a new key, returns the key which is not stored anywhere
+func CreateKey(ctx context.Context, ex Execer, name, role string, scopes []string, createdBy string) (APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", err
+	}
+	key := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := APIKey{NAME: name, PREFIX: key[:len(keyPrefix)+4], ROLE: role, SCOPES: scopes, CREATED_BY: createdBy, CREATED_AT: time.Now().UTC()}
+	if apiKey.SCOPES == nil {
+		apiKey.SCOPES = []string{}
+	}
+	res, err := ex.ExecContext(ctx, `INSERT INTO api_keys (name, key_hash, prefix, role, scopes, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		apiKey.NAME, HashKey(key), apiKey.PREFIX, apiKey.ROLE, strings.Join(apiKey.SCOPES, " "), apiKey.CREATED_BY, apiKey.CREATED_AT)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if apiKey.ID, err = res.LastInsertId(); err != nil {
+		return APIKey{}, "", err
+	}
+	return apiKey, key, nil
+}
+
+// Revoke a key, returns sql.ErrNoRows if there is no such key that is not revoked yet
+func RevokeKey(ctx context.Context, ex Execer, id int64) (APIKey, error) {
+	key, err := scanKey(ex.QueryRowContext(ctx, keyQuery+` WHERE id = ? AND revoked_at IS NULL`, id))
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	now := time.Now().UTC()
+	if _, err := ex.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ?`, now, id); err != nil {
+		return APIKey{}, err
+	}
+	key.REVOKED_AT = &now
+	return key, nil
+}
+
+// Keys are long random strings, a plain SHA-256 is enough to keep them from being usable if the table leaks
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+const keyQuery = `SELECT id, name, prefix, role, scopes, created_by, created_at, revoked_at FROM api_keys`
+
+// *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(row scanner) (APIKey, error) {
+	var key APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+	if err := row.Scan(&key.ID, &key.NAME, &key.PREFIX, &key.ROLE, &scopes, &key.CREATED_BY, &key.CREATED_AT, &revokedAt); err != nil {
+		return APIKey{}, err
+	}
+	key.SCOPES = splitScopes(scopes)
+	if revokedAt.Valid {
+		key.REVOKED_AT = &revokedAt.Time
+	}
+	return key, nil
+}
+
+func splitScopes(scopes string) []string {
+	fields := strings.Fields(scopes)
+	if fields == nil {
+		return []string{}
+	}
+	return fields
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("^INSERT INTO api_keys").
+		WithArgs("batch-job", sqlmock.AnyArg(), sqlmock.AnyArg(), RoleSteward, "delete", "admin", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+
+	apiKey, key, err := CreateKey(context.Background(), db, "batch-job", RoleSteward, []string{ScopeDelete}, "admin")
+	assert.NoError(t, err, "CreateKey should not return an error")
+	assert.Equal(t, int64(7), apiKey.ID)
+	assert.True(t, strings.HasPrefix(key, keyPrefix), "Key should have the swk_ prefix")
+	assert.True(t, strings.HasPrefix(key, apiKey.PREFIX), "Stored prefix should identify the key")
+	assert.Greater(t, len(key), 40)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestStoredKeyAuthentication(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	a := New(nil, nil, nil, NewKeyStore(db, time.Second))
+
+	// Only the hash of the key is sent to the database
+	mock.ExpectQuery("SELECT name, role, scopes FROM api_keys WHERE key_hash = \\? AND revoked_at IS NULL").
+		WithArgs(HashKey("swk_stored")).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "role", "scopes"}).AddRow("batch-job", RoleReader, "write"))
+	mock.ExpectQuery("SELECT name, role, scopes FROM api_keys").
+		WithArgs(HashKey("swk_revoked")).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "role", "scopes"}))
+	mock.ExpectQuery("SELECT name, role, scopes FROM api_keys").
+		WithArgs(HashKey("swk_down")).
+		WillReturnError(sql.ErrConnDone)
+
+	principal, err := a.Authenticate(request("X-API-Key", "swk_stored"))
+	assert.NoError(t, err)
+	assert.Equal(t, Principal{"batch-job", MethodAPIKey, RoleReader, []string{ScopeRead, ScopeWrite}}, principal)
+
+	_, err = a.Authenticate(request("X-API-Key", "swk_revoked"))
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "Revoked key should be rejected")
+
+	_, err = a.Authenticate(request("X-API-Key", "swk_down"))
+	assert.ErrorIs(t, err, sql.ErrConnDone, "Database errors should not look like wrong credentials")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	columns := []string{"id", "name", "prefix", "role", "scopes", "created_by", "created_at", "revoked_at"}
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM api_keys WHERE id = \\? AND revoked_at IS NULL").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "batch-job", "swk_abcd", RoleReader, "", "admin", created, nil))
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = \\? WHERE id = \\?").WithArgs(sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM api_keys WHERE id = \\? AND revoked_at IS NULL").WithArgs(int64(8)).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	key, err := RevokeKey(context.Background(), db, 7)
+	assert.NoError(t, err, "RevokeKey should not return an error")
+	assert.NotNil(t, key.REVOKED_AT)
+	assert.Equal(t, []string{}, key.SCOPES)
+
+	_, err = RevokeKey(context.Background(), db, 8)
+	assert.Equal(t, sql.ErrNoRows, err, "Unknown key should not be revoked")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestStoreErrorMiddleware(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT name, role, scopes FROM api_keys").WillReturnError(sql.ErrConnDone)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", New(nil, nil, nil, NewKeyStore(db, time.Second)).Middleware(true), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	req := request("X-API-Key", "swk_any")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code, "Unavailable key store is not a client error")
+}
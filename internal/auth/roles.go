@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"net/http"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+	ScopeImport = "import"
+	ScopeAdmin  = "admin"
+)
+
+const (
+	RoleReader  = "reader"
+	RoleSteward = "steward"
+	RoleAdmin   = "admin"
+)
+
+// Scopes granted by each role, keys and tokens can add scopes on top
+var roleScopes = map[string][]string{
+	RoleReader:  {ScopeRead},
+	RoleSteward: {ScopeRead, ScopeWrite},
+	RoleAdmin:   {ScopeRead, ScopeWrite, ScopeDelete, ScopeImport, ScopeAdmin},
+}
+
+var allScopes = roleScopes[RoleAdmin]
+
+func ValidRole(role string) bool {
+	_, ok := roleScopes[role]
+	return ok
+}
+
+func ValidScope(scope string) bool {
+	for _, known := range allScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, granted := range p.SCOPES {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal with scopes of its role and known extra scopes, in the order of allScopes
+func newPrincipal(name, method, role string, extra []string) Principal {
+	granted := map[string]bool{}
+	for _, scope := range roleScopes[role] {
+		granted[scope] = true
+	}
+	for _, scope := range extra {
+		granted[scope] = true
+	}
+
+	principal := Principal{NAME: name, METHOD: method, ROLE: role, SCOPES: []string{}}
+	for _, scope := range allScopes {
+		if granted[scope] {
+			principal.SCOPES = append(principal.SCOPES, scope)
+		}
+	}
+	return principal
+}
+
+// Gin middleware rejecting requests whose principal lacks scope, goes after Middleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := PrincipalOf(c)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="swift-api"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Missing credentials, use X-API-Key header or Authorization: Bearer token", "requestId": logging.RequestID(c.Request.Context())})
+			return
+		}
+		if !principal.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "Missing " + scope + " scope for " + principal.NAME, "requestId": logging.RequestID(c.Request.Context())})
+			return
+		}
+		c.Next()
+	}
+}
+
+// True if request was authenticated with scope
+func HasScope(c *gin.Context, scope string) bool {
+	principal, ok := PrincipalOf(c)
+	return ok && principal.HasScope(scope)
+}
@@ -0,0 +1,34 @@
+package cache
+
+// Response cache, values are rendered responses so a shared cache can store them as they are
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(keys ...string)
+	// Drop every key starting with prefix, empty prefix clears the cache
+	DeletePrefix(prefix string)
+	Stats() Stats
+}
+
+type Stats struct {
+	HITS    uint64 `json:"hits"`
+	MISSES  uint64 `json:"misses"`
+	ENTRIES int    `json:"entries"`
+}
+
+// Share of lookups served from cache, 0 before the first lookup
+func (s Stats) HitRatio() float64 {
+	if s.HITS+s.MISSES == 0 {
+		return 0
+	}
+	return float64(s.HITS) / float64(s.HITS+s.MISSES)
+}
+
+// Cache that never stores anything, used when caching is turned off
+type Nop struct{}
+
+func (Nop) Get(key string) ([]byte, bool) { return nil, false }
+func (Nop) Set(key string, value []byte)  {}
+func (Nop) Delete(keys ...string)         {}
+func (Nop) DeletePrefix(prefix string)    {}
+func (Nop) Stats() Stats                  { return Stats{} }
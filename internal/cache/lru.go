@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// In-process cache keeping the most recently used entries, each entry lives for ttl
+type LRU struct {
+	size   int
+	ttl    time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+	items  map[string]*list.Element
+	order  *list.List // front is most recently used
+	hits   uint64
+	misses uint64
+}
+
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{size: size, ttl: ttl, now: time.Now, items: map[string]*list.Element{}, order: list.New()}
+}
+
+func (l *LRU) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.items[key]
+	if ok && l.now().After(element.Value.(*entry).expires) {
+		l.remove(element)
+		ok = false
+	}
+	if !ok {
+		l.misses++
+		return nil, false
+	}
+	l.hits++
+	l.order.MoveToFront(element)
+	return element.Value.(*entry).value, true
+}
+
+func (l *LRU) Set(key string, value []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := l.now().Add(l.ttl)
+	if element, ok := l.items[key]; ok {
+		element.Value = &entry{key, value, expires}
+		l.order.MoveToFront(element)
+		return
+	}
+	l.items[key] = l.order.PushFront(&entry{key, value, expires})
+	if l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRU) Delete(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := l.items[key]; ok {
+			l.remove(element)
+		}
+	}
+}
+
+func (l *LRU) DeletePrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, element := range l.items {
+		if strings.HasPrefix(key, prefix) {
+			l.remove(element)
+		}
+	}
+}
+
+func (l *LRU) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{HITS: l.hits, MISSES: l.misses, ENTRIES: len(l.items)}
+}
+
+func (l *LRU) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.items, element.Value.(*entry).key)
+}
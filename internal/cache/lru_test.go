@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	l := NewLRU(2, time.Minute)
+
+	_, ok := l.Get("a")
+	assert.False(t, ok)
+
+	l.Set("a", []byte("1"))
+	l.Set("b", []byte("2"))
+	value, ok := l.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(value))
+
+	// b is least recently used now
+	l.Set("c", []byte("3"))
+	_, ok = l.Get("b")
+	assert.False(t, ok, "Least recently used entry should be evicted")
+	_, ok = l.Get("a")
+	assert.True(t, ok)
+
+	assert.Equal(t, Stats{HITS: 2, MISSES: 2, ENTRIES: 2}, l.Stats())
+	assert.Equal(t, 0.5, l.Stats().HitRatio())
+}
+
+func TestLRUExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLRU(10, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Set("a", []byte("1"))
+	now = now.Add(2 * time.Minute)
+
+	_, ok := l.Get("a")
+	assert.False(t, ok, "Expired entry should not be served")
+	assert.Equal(t, 0, l.Stats().ENTRIES)
+}
+
+func TestLRUDelete(t *testing.T) {
+	l := NewLRU(10, time.Minute)
+	l.Set("branch:AAAABBCCXXX", []byte("1"))
+	l.Set("branch:AAAABBCC123", []byte("2"))
+	l.Set("country:BB", []byte("3"))
+
+	l.Delete("branch:AAAABBCC123", "branch:unknown")
+	_, ok := l.Get("branch:AAAABBCC123")
+	assert.False(t, ok)
+
+	l.DeletePrefix("country:")
+	_, ok = l.Get("country:BB")
+	assert.False(t, ok)
+	_, ok = l.Get("branch:AAAABBCCXXX")
+	assert.True(t, ok, "Other prefixes should stay")
+
+	l.DeletePrefix("")
+	assert.Equal(t, 0, l.Stats().ENTRIES)
+}
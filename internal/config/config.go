@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Import policies on startup
+const (
+	ImportAlways  = "always"
+	ImportIfEmpty = "ifEmpty"
+	ImportNever   = "never"
+)
+
+// Trace exporters, none keeps trace ids in logs without exporting spans
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Log levels, debug also turns on gin debug mode
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+type Config struct {
+	SERVER     Server    `yaml:"server"`
+	DATABASE   Database  `yaml:"database"`
+	IMPORT     Import    `yaml:"import"`
+	AUTH       Auth      `yaml:"auth"`
+	RATE_LIMIT RateLimit `yaml:"rateLimit"`
+	CACHE      Cache     `yaml:"cache"`
+	TIMEOUTS   Timeouts  `yaml:"timeouts"`
+	TRACING    Tracing   `yaml:"tracing"`
+	LOG        Log       `yaml:"log"`
+}
+
+type Server struct {
+	LISTEN_ADDRESS    string        `yaml:"listenAddress"`
+	TRUSTED_PROXIES   []string      `yaml:"trustedProxies"`
+	REQUIRE_IF_MATCH  bool          `yaml:"requireIfMatch"`
+	DELETED_RETENTION time.Duration `yaml:"deletedRetention"`
+	SHUTDOWN_TIMEOUT  time.Duration `yaml:"shutdownTimeout"`
+}
+
+// DSN wins over the separate connection fields when set
+type Database struct {
+	DSN               string        `yaml:"dsn"`
+	HOST              string        `yaml:"host"`
+	PORT              int           `yaml:"port"`
+	USER              string        `yaml:"user"`
+	PASSWORD          string        `yaml:"password"`
+	NAME              string        `yaml:"name"`
+	MAX_OPEN_CONNS    int           `yaml:"maxOpenConns"`
+	MAX_IDLE_CONNS    int           `yaml:"maxIdleConns"`
+	CONN_MAX_LIFETIME time.Duration `yaml:"connMaxLifetime"`
+	CONNECT_TIMEOUT   time.Duration `yaml:"connectTimeout"`
+	RETRY_INITIAL     time.Duration `yaml:"retryInitial"`
+	RETRY_MAX         time.Duration `yaml:"retryMax"`
+	PING_INTERVAL     time.Duration `yaml:"pingInterval"`
+}
+
+type Import struct {
+	POLICY                  string `yaml:"policy"`
+	CSV_PATH                string `yaml:"csvPath"`
+	AUTO_REGISTER_COUNTRIES bool   `yaml:"autoRegisterCountries"`
+}
+
+type Auth struct {
+	API_KEYS                  string `yaml:"apiKeys"`
+	JWT_HS256_SECRET          string `yaml:"jwtHS256Secret"`
+	JWT_RS256_PUBLIC_KEY_FILE string `yaml:"jwtRS256PublicKeyFile"`
+	JWT_ISSUER                string `yaml:"jwtIssuer"`
+	JWT_AUDIENCE              string `yaml:"jwtAudience"`
+	PUBLIC_READS              bool   `yaml:"publicReads"`
+}
+
+type RateLimit struct {
+	DEFAULT       string `yaml:"default"`
+	ROUTES        string `yaml:"routes"`
+	AUTH_FAILURES string `yaml:"authFailures"`
+}
+
+type Cache struct {
+	SIZE                 int           `yaml:"size"`
+	TTL                  time.Duration `yaml:"ttl"`
+	CACHE_CONTROL_ROUTES string        `yaml:"cacheControlRoutes"`
+}
+
+// How long storage calls may take, requests past it get 504
+type Timeouts struct {
+	READ  time.Duration `yaml:"read"`
+	WRITE time.Duration `yaml:"write"`
+	BULK  time.Duration `yaml:"bulk"`
+}
+
+// ENDPOINT is the OTLP/HTTP collector URL, used by the otlp exporter only
+type Tracing struct {
+	EXPORTER     string  `yaml:"exporter"`
+	ENDPOINT     string  `yaml:"endpoint"`
+	SAMPLE_RATIO float64 `yaml:"sampleRatio"`
+	SERVICE_NAME string  `yaml:"serviceName"`
+}
+
+type Log struct {
+	LEVEL string `yaml:"level"`
+}
+
+// All problems found in configuration, reported together
+type Error struct {
+	PROBLEMS []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.PROBLEMS, "\n  - ")
+}
+
+func Default() Config {
+	return Config{
+		SERVER: Server{
+			LISTEN_ADDRESS:    "0.0.0.0:8080",
+			DELETED_RETENTION: 720 * time.Hour,
+			SHUTDOWN_TIMEOUT:  20 * time.Second,
+		},
+		DATABASE: Database{
+			PORT:              3306,
+			USER:              "root",
+			NAME:              "mydb",
+			MAX_OPEN_CONNS:    25,
+			MAX_IDLE_CONNS:    25,
+			CONN_MAX_LIFETIME: 5 * time.Minute,
+			CONNECT_TIMEOUT:   time.Minute,
+			RETRY_INITIAL:     500 * time.Millisecond,
+			RETRY_MAX:         10 * time.Second,
+			PING_INTERVAL:     15 * time.Second,
+		},
+		IMPORT: Import{
+			POLICY:   ImportAlways,
+			CSV_PATH: "../../internal/data/SWIFT_CODES.csv",
+		},
+		AUTH:       Auth{PUBLIC_READS: true},
+		RATE_LIMIT: RateLimit{DEFAULT: "600/m", AUTH_FAILURES: "20/m"},
+		CACHE: Cache{
+			SIZE:                 10000,
+			TTL:                  5 * time.Minute,
+			CACHE_CONTROL_ROUTES: "GET /v1/swift-codes/country/:countryISO2code=no-cache",
+		},
+		TIMEOUTS: Timeouts{
+			READ:  5 * time.Second,
+			WRITE: 10 * time.Second,
+			BULK:  30 * time.Minute,
+		},
+		TRACING: Tracing{
+			EXPORTER:     ExporterNone,
+			ENDPOINT:     "http://localhost:4318",
+			SAMPLE_RATIO: 1,
+			SERVICE_NAME: "swift-api",
+		},
+		LOG: Log{LEVEL: LevelInfo},
+	}
+}
+
+// Load configuration from defaults, YAML file, environment and command-line flags, later sources win.
+// File is given with -config or CONFIG_FILE
+func Load(args []string) (Config, error) {
+	return load(args, os.LookupEnv)
+}
+
+func load(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	conf := Default()
+	var problems []string
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", "", "YAML configuration file, same as CONFIG_FILE")
+	for _, s := range settings {
+		fs.String(s.flagName(), "", s.usage+", same as "+s.env)
+	}
+	if err := fs.Parse(args); err != nil {
+		return conf, err
+	}
+
+	path, _ := lookupEnv("CONFIG_FILE")
+	if *configFile != "" {
+		path = *configFile
+	}
+	if path != "" {
+		if err := readFile(path, &conf); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(&conf, value); err != nil {
+				problems = append(problems, s.env+": "+err.Error())
+			}
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flagName() == f.Name {
+				if err := s.set(&conf, f.Value.String()); err != nil {
+					problems = append(problems, "-"+f.Name+": "+err.Error())
+				}
+			}
+		}
+	})
+
+	problems = append(problems, conf.Validate()...)
+	if len(problems) > 0 {
+		return conf, &Error{problems}
+	}
+	return conf, nil
+}
+
+// Unknown keys are reported, they are usually typos
+func readFile(path string, conf *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(conf); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	csv := writeFile(t, "codes.csv", "")
+	base := map[string]string{"DB_HOST": "localhost", "DB_PASSWORD": "secret", "IMPORT_CSV_PATH": csv}
+
+	// Test 1: Defaults with required environment
+	t.Run("Defaults", func(t *testing.T) {
+		conf, err := load(nil, lookup(base))
+		assert.NoError(t, err)
+		assert.Equal(t, "0.0.0.0:8080", conf.SERVER.LISTEN_ADDRESS)
+		assert.Equal(t, "localhost", conf.DATABASE.HOST)
+		assert.Equal(t, 3306, conf.DATABASE.PORT)
+		assert.Equal(t, ImportAlways, conf.IMPORT.POLICY)
+		assert.True(t, conf.AUTH.PUBLIC_READS)
+		assert.Equal(t, LevelInfo, conf.LOG.LEVEL)
+	})
+
+	// Test 2: File < environment < flags
+	t.Run("Precedence", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", `
+server:
+  listenAddress: 127.0.0.1:9000
+database:
+  port: 3307
+  maxOpenConns: 10
+  maxIdleConns: 5
+cache:
+  ttl: 1m
+log:
+  level: warn
+`)
+		env := map[string]string{"CONFIG_FILE": file, "DB_PORT": "3308", "CACHE_TTL": "2m"}
+		for key, value := range base {
+			env[key] = value
+		}
+		conf, err := load([]string{"-cache-ttl", "3m", "-log-level", "debug"}, lookup(env))
+		assert.NoError(t, err)
+		assert.Equal(t, "127.0.0.1:9000", conf.SERVER.LISTEN_ADDRESS, "File should override defaults")
+		assert.Equal(t, 10, conf.DATABASE.MAX_OPEN_CONNS)
+		assert.Equal(t, 3308, conf.DATABASE.PORT, "Environment should override file")
+		assert.Equal(t, 3*time.Minute, conf.CACHE.TTL, "Flags should override environment")
+		assert.Equal(t, LevelDebug, conf.LOG.LEVEL)
+	})
+
+	// Test 3: -config flag wins over CONFIG_FILE, empty variables are ignored
+	t.Run("ConfigFlag", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", "import:\n  policy: never\n")
+		env := map[string]string{"CONFIG_FILE": "missing.yaml", "LOG_LEVEL": ""}
+		for key, value := range base {
+			env[key] = value
+		}
+		conf, err := load([]string{"-config", file}, lookup(env))
+		assert.NoError(t, err)
+		assert.Equal(t, ImportNever, conf.IMPORT.POLICY)
+		assert.Equal(t, LevelInfo, conf.LOG.LEVEL)
+	})
+
+	// Test 4: Unknown keys in file
+	t.Run("UnknownKey", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", "database:\n  hots: localhost\n")
+		env := map[string]string{"CONFIG_FILE": file}
+		for key, value := range base {
+			env[key] = value
+		}
+		_, err := load(nil, lookup(env))
+		assert.ErrorContains(t, err, "field hots not found")
+	})
+
+	// Test 5: All problems reported together
+	t.Run("Problems", func(t *testing.T) {
+		env := map[string]string{
+			"DB_PORT":           "70000",
+			"DB_MAX_OPEN_CONNS": "5",
+			"DB_MAX_IDLE_CONNS": "10",
+			"IMPORT_POLICY":     "sometimes",
+			"RATE_LIMIT":        "fast",
+			"CACHE_TTL":         "soon",
+			"PUBLIC_READS":      "yes",
+			"TRACING_EXPORTER":  "otlp",
+			"TRACING_ENDPOINT":  "localhost:4318",
+		}
+		_, err := load([]string{"-log-level", "verbose"}, lookup(env))
+		var confErr *Error
+		if assert.ErrorAs(t, err, &confErr) {
+			assert.Len(t, confErr.PROBLEMS, 10)
+		}
+		for _, expected := range []string{
+			"CACHE_TTL: wrong duration",
+			"PUBLIC_READS: wrong boolean",
+			"database.host: required",
+			"database.password: required",
+			"database.port: must be between",
+			"database.maxIdleConns",
+			"import.policy: wrong policy",
+			"rateLimit.default",
+			"tracing.endpoint: wrong URL",
+			"log.level: wrong level",
+		} {
+			assert.ErrorContains(t, err, expected)
+		}
+	})
+
+	// Test 6: DSN replaces the separate fields
+	t.Run("DSN", func(t *testing.T) {
+		env := map[string]string{"DB_DSN": "app:pass@tcp(db:3306)/swift", "IMPORT_POLICY": "never"}
+		conf, err := load(nil, lookup(env))
+		assert.NoError(t, err)
+		assert.Equal(t, "app:pass@tcp(db:3306)/swift?parseTime=true", conf.DATABASE.DataSourceName())
+
+		_, err = load(nil, lookup(map[string]string{"DB_DSN": "not a dsn", "IMPORT_POLICY": "never"}))
+		assert.ErrorContains(t, err, "database.dsn")
+	})
+}
+
+func TestDataSourceName(t *testing.T) {
+	database := Database{HOST: "localhost", PORT: 3306, USER: "root", PASSWORD: "secret", NAME: "mydb"}
+	assert.Equal(t, "root:secret@tcp(localhost:3306)/mydb?parseTime=true", database.DataSourceName())
+}
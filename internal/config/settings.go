@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting that can come from an environment variable or a command-line flag.
+// Flag name is the variable name in lower case with dashes, DB_HOST is -db-host
+type setting struct {
+	env   string
+	usage string
+	set   func(conf *Config, value string) error
+}
+
+func (s setting) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+}
+
+var settings = []setting{
+	{"LISTEN_ADDRESS", "address the HTTP server listens on", str(func(c *Config) *string { return &c.SERVER.LISTEN_ADDRESS })},
+	{"TRUSTED_PROXIES", "comma separated proxies allowed to set X-Forwarded-For", list(func(c *Config) *[]string { return &c.SERVER.TRUSTED_PROXIES })},
+	{"REQUIRE_IF_MATCH", "reject branch changes without If-Match", boolean(func(c *Config) *bool { return &c.SERVER.REQUIRE_IF_MATCH })},
+	{"DELETED_RETENTION", "how long soft deleted branches are kept", duration(func(c *Config) *time.Duration { return &c.SERVER.DELETED_RETENTION })},
+	{"SHUTDOWN_TIMEOUT", "how long in-flight requests may finish on shutdown", duration(func(c *Config) *time.Duration { return &c.SERVER.SHUTDOWN_TIMEOUT })},
+
+	{"DB_DSN", "MySQL data source name, replaces the other DB_ settings", str(func(c *Config) *string { return &c.DATABASE.DSN })},
+	{"DB_HOST", "MySQL host", str(func(c *Config) *string { return &c.DATABASE.HOST })},
+	{"DB_PORT", "MySQL port", integer(func(c *Config) *int { return &c.DATABASE.PORT })},
+	{"DB_USER", "MySQL user", str(func(c *Config) *string { return &c.DATABASE.USER })},
+	{"DB_PASSWORD", "MySQL password", str(func(c *Config) *string { return &c.DATABASE.PASSWORD })},
+	{"DB_NAME", "MySQL database", str(func(c *Config) *string { return &c.DATABASE.NAME })},
+	{"DB_MAX_OPEN_CONNS", "maximum open connections, 0 is unlimited", integer(func(c *Config) *int { return &c.DATABASE.MAX_OPEN_CONNS })},
+	{"DB_MAX_IDLE_CONNS", "maximum idle connections", integer(func(c *Config) *int { return &c.DATABASE.MAX_IDLE_CONNS })},
+	{"DB_CONN_MAX_LIFETIME", "maximum time a connection is reused, 0 is forever", duration(func(c *Config) *time.Duration { return &c.DATABASE.CONN_MAX_LIFETIME })},
+	{"DB_CONNECT_TIMEOUT", "how long to wait for the database on startup", duration(func(c *Config) *time.Duration { return &c.DATABASE.CONNECT_TIMEOUT })},
+	{"DB_RETRY_INITIAL", "first delay between connection tries, doubled after each failure", duration(func(c *Config) *time.Duration { return &c.DATABASE.RETRY_INITIAL })},
+	{"DB_RETRY_MAX", "longest delay between connection tries", duration(func(c *Config) *time.Duration { return &c.DATABASE.RETRY_MAX })},
+	{"DB_PING_INTERVAL", "how often the database connection is checked", duration(func(c *Config) *time.Duration { return &c.DATABASE.PING_INTERVAL })},
+
+	{"IMPORT_POLICY", "CSV import on startup: always, ifEmpty or never", str(func(c *Config) *string { return &c.IMPORT.POLICY })},
+	{"IMPORT_CSV_PATH", "SWIFT codes CSV file", str(func(c *Config) *string { return &c.IMPORT.CSV_PATH })},
+	{"AUTO_REGISTER_COUNTRIES", "add unknown countries of new branches", boolean(func(c *Config) *bool { return &c.IMPORT.AUTO_REGISTER_COUNTRIES })},
+
+	{"API_KEYS", "comma separated name:key:role API keys", str(func(c *Config) *string { return &c.AUTH.API_KEYS })},
+	{"JWT_HS256_SECRET", "secret of HS256 signed tokens", str(func(c *Config) *string { return &c.AUTH.JWT_HS256_SECRET })},
+	{"JWT_RS256_PUBLIC_KEY_FILE", "PEM public key of RS256 signed tokens", str(func(c *Config) *string { return &c.AUTH.JWT_RS256_PUBLIC_KEY_FILE })},
+	{"JWT_ISSUER", "required iss claim of tokens", str(func(c *Config) *string { return &c.AUTH.JWT_ISSUER })},
+	{"JWT_AUDIENCE", "required aud claim of tokens", str(func(c *Config) *string { return &c.AUTH.JWT_AUDIENCE })},
+	{"PUBLIC_READS", "allow reads without credentials", boolean(func(c *Config) *bool { return &c.AUTH.PUBLIC_READS })},
+
+	{"RATE_LIMIT", "default rate limit per client like 600/m, off to disable", str(func(c *Config) *string { return &c.RATE_LIMIT.DEFAULT })},
+	{"RATE_LIMIT_ROUTES", "per route rate limits like GET /v1/banks=20/s", str(func(c *Config) *string { return &c.RATE_LIMIT.ROUTES })},
+	{"RATE_LIMIT_AUTH_FAILURES", "failed authentications per IP like 20/m, off to disable", str(func(c *Config) *string { return &c.RATE_LIMIT.AUTH_FAILURES })},
+
+	{"CACHE_SIZE", "cached responses, 0 disables the cache", integer(func(c *Config) *int { return &c.CACHE.SIZE })},
+	{"CACHE_TTL", "lifetime of cached responses", duration(func(c *Config) *time.Duration { return &c.CACHE.TTL })},
+	{"CACHE_CONTROL_ROUTES", "per route Cache-Control separated by ;", str(func(c *Config) *string { return &c.CACHE.CACHE_CONTROL_ROUTES })},
+
+	{"READ_TIMEOUT", "longest database work of a read request", duration(func(c *Config) *time.Duration { return &c.TIMEOUTS.READ })},
+	{"WRITE_TIMEOUT", "longest database work of a write request", duration(func(c *Config) *time.Duration { return &c.TIMEOUTS.WRITE })},
+	{"BULK_TIMEOUT", "longest CSV import, search index rebuild or purge", duration(func(c *Config) *time.Duration { return &c.TIMEOUTS.BULK })},
+
+	{"TRACING_EXPORTER", "where spans go: none, otlp or stdout", str(func(c *Config) *string { return &c.TRACING.EXPORTER })},
+	{"TRACING_ENDPOINT", "OTLP/HTTP collector URL", str(func(c *Config) *string { return &c.TRACING.ENDPOINT })},
+	{"TRACING_SAMPLE_RATIO", "share of new traces recorded, from 0 to 1", number(func(c *Config) *float64 { return &c.TRACING.SAMPLE_RATIO })},
+	{"TRACING_SERVICE_NAME", "service name on exported spans", str(func(c *Config) *string { return &c.TRACING.SERVICE_NAME })},
+
+	{"LOG_LEVEL", "debug, info, warn or error", str(func(c *Config) *string { return &c.LOG.LEVEL })},
+}
+
+func str(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func list(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*field(c) = values
+		return nil
+	}
+}
+
+func boolean(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("wrong boolean %q, use true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func integer(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("wrong number %q", value)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func number(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("wrong number %q", value)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func duration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("wrong duration %q, use a Go duration like 5m", value)
+		}
+		*field(c) = d
+		return nil
+	}
+}
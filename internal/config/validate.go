@@ -0,0 +1,160 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/auth"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/httpcache"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/ratelimit"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Check the whole configuration, returns every problem found
+func (c Config) Validate() []string {
+	var problems []string
+	problem := func(key, message string) {
+		problems = append(problems, key+": "+message)
+	}
+
+	if _, _, err := net.SplitHostPort(c.SERVER.LISTEN_ADDRESS); err != nil {
+		problem("server.listenAddress", "wrong address "+strconv.Quote(c.SERVER.LISTEN_ADDRESS)+", use host:port")
+	}
+	for _, proxy := range c.SERVER.TRUSTED_PROXIES {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problem("server.trustedProxies", "wrong IP or CIDR "+strconv.Quote(proxy))
+			}
+		}
+	}
+	if c.SERVER.DELETED_RETENTION <= 0 {
+		problem("server.deletedRetention", "must be positive")
+	}
+	if c.SERVER.SHUTDOWN_TIMEOUT <= 0 {
+		problem("server.shutdownTimeout", "must be positive")
+	}
+
+	if c.DATABASE.DSN != "" {
+		if _, err := mysql.ParseDSN(c.DATABASE.DSN); err != nil {
+			problem("database.dsn", err.Error())
+		}
+	} else {
+		if c.DATABASE.HOST == "" {
+			problem("database.host", "required unless database.dsn is set (DB_HOST)")
+		}
+		if c.DATABASE.PASSWORD == "" {
+			problem("database.password", "required unless database.dsn is set (DB_PASSWORD)")
+		}
+		if c.DATABASE.PORT <= 0 || c.DATABASE.PORT > 65535 {
+			problem("database.port", "must be between 1 and 65535")
+		}
+	}
+	if c.DATABASE.MAX_OPEN_CONNS < 0 || c.DATABASE.MAX_IDLE_CONNS < 0 || c.DATABASE.CONN_MAX_LIFETIME < 0 {
+		problem("database", "pool settings can't be negative")
+	}
+	if c.DATABASE.MAX_OPEN_CONNS > 0 && c.DATABASE.MAX_IDLE_CONNS > c.DATABASE.MAX_OPEN_CONNS {
+		problem("database.maxIdleConns", "can't be above database.maxOpenConns")
+	}
+	if c.DATABASE.CONNECT_TIMEOUT <= 0 {
+		problem("database.connectTimeout", "must be positive")
+	}
+	if c.DATABASE.RETRY_INITIAL <= 0 {
+		problem("database.retryInitial", "must be positive")
+	}
+	if c.DATABASE.RETRY_MAX < c.DATABASE.RETRY_INITIAL {
+		problem("database.retryMax", "can't be below database.retryInitial")
+	}
+	if c.DATABASE.PING_INTERVAL <= 0 {
+		problem("database.pingInterval", "must be positive")
+	}
+
+	switch c.IMPORT.POLICY {
+	case ImportAlways, ImportIfEmpty:
+		if _, err := os.Stat(c.IMPORT.CSV_PATH); err != nil {
+			problem("import.csvPath", err.Error())
+		}
+	case ImportNever:
+	default:
+		problem("import.policy", "wrong policy "+strconv.Quote(c.IMPORT.POLICY)+", use always, ifEmpty or never")
+	}
+
+	if _, err := auth.ParseAPIKeys(c.AUTH.API_KEYS); err != nil {
+		problem("auth.apiKeys", err.Error())
+	}
+	if path := c.AUTH.JWT_RS256_PUBLIC_KEY_FILE; path != "" {
+		if pem, err := os.ReadFile(path); err != nil {
+			problem("auth.jwtRS256PublicKeyFile", err.Error())
+		} else if _, err := auth.ParseRSAPublicKey(pem); err != nil {
+			problem("auth.jwtRS256PublicKeyFile", err.Error())
+		}
+	}
+
+	if c.RATE_LIMIT.DEFAULT != "off" {
+		if _, err := ratelimit.ParseLimit(c.RATE_LIMIT.DEFAULT); err != nil {
+			problem("rateLimit.default", err.Error())
+		}
+	}
+	if _, err := ratelimit.ParseRoutes(c.RATE_LIMIT.ROUTES); err != nil {
+		problem("rateLimit.routes", err.Error())
+	}
+	if c.RATE_LIMIT.AUTH_FAILURES != "off" {
+		if _, err := ratelimit.ParseLimit(c.RATE_LIMIT.AUTH_FAILURES); err != nil {
+			problem("rateLimit.authFailures", err.Error())
+		}
+	}
+
+	if c.CACHE.SIZE < 0 {
+		problem("cache.size", "can't be negative")
+	}
+	if c.CACHE.TTL <= 0 {
+		problem("cache.ttl", "must be positive")
+	}
+	if _, err := httpcache.ParseCacheControl(c.CACHE.CACHE_CONTROL_ROUTES); err != nil {
+		problem("cache.cacheControlRoutes", err.Error())
+	}
+
+	if c.TIMEOUTS.READ <= 0 || c.TIMEOUTS.WRITE <= 0 || c.TIMEOUTS.BULK <= 0 {
+		problem("timeouts", "must be positive")
+	}
+
+	switch c.TRACING.EXPORTER {
+	case ExporterNone, ExporterStdout:
+	case ExporterOTLP:
+		if u, err := url.Parse(c.TRACING.ENDPOINT); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problem("tracing.endpoint", "wrong URL "+strconv.Quote(c.TRACING.ENDPOINT)+", use http://host:port")
+		}
+	default:
+		problem("tracing.exporter", "wrong exporter "+strconv.Quote(c.TRACING.EXPORTER)+", use none, otlp or stdout")
+	}
+	if c.TRACING.SAMPLE_RATIO < 0 || c.TRACING.SAMPLE_RATIO > 1 {
+		problem("tracing.sampleRatio", "must be between 0 and 1")
+	}
+	if c.TRACING.SERVICE_NAME == "" {
+		problem("tracing.serviceName", "required")
+	}
+
+	switch c.LOG.LEVEL {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+	default:
+		problem("log.level", "wrong level "+strconv.Quote(c.LOG.LEVEL)+", use debug, info, warn or error")
+	}
+	return problems
+}
+
+// Data source name for the MySQL driver, times are always parsed into time.Time
+func (d Database) DataSourceName() string {
+	conf, err := mysql.ParseDSN(d.DSN)
+	if d.DSN == "" || err != nil {
+		conf = mysql.NewConfig()
+		conf.User = d.USER
+		conf.Passwd = d.PASSWORD
+		conf.Net = "tcp"
+		conf.Addr = net.JoinHostPort(d.HOST, strconv.Itoa(d.PORT))
+		conf.DBName = d.NAME
+	}
+	conf.ParseTime = true
+	return conf.FormatDSN()
+}
@@ -1,64 +1,53 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+
+	"github.com/XSAM/otelsql"
 	_ "github.com/go-sql-driver/mysql" // MySQL driver
 )
 
-func Connect() (*sql.DB, error) {
-	// Read environment variables
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		log.Fatal("DB_HOST environment variable is not set")
-	}
-
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = "3306"
-	}
-
-	dbUser := os.Getenv("DB_USER")
-	if dbUser == "" {
-		dbUser = "root"
-	}
-
-	dbPassword := os.Getenv("DB_PASSWORD")
-	if dbPassword == "" {
-		log.Fatal("DB_PASSWORD environment variable is not set")
+// Connect with settings from configuration, waits for the database until it answers or ctx is done.
+// Connection pool limits are applied before the first ping, storage calls are traced as set in traceOptions
+func Connect(ctx context.Context, conf config.Database) (*sql.DB, error) {
+	db, err := otelsql.Open("mysql", conf.DataSourceName(), traceOptions...)
+	if err != nil {
+		return nil, err
 	}
+	db.SetMaxOpenConns(conf.MAX_OPEN_CONNS)
+	db.SetMaxIdleConns(conf.MAX_IDLE_CONNS)
+	db.SetConnMaxLifetime(conf.CONN_MAX_LIFETIME)
 
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		dbName = "mydb"
+	if err := waitForDatabase(ctx, db, RetryOf(conf)); err != nil {
+		db.Close()
+		return nil, err
 	}
+	slog.Info("Connected to database")
+	return db, nil
+}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", dbUser, dbPassword, dbHost, dbPort, dbName)
-	var db *sql.DB
-	var err error
-	// Try to connect to database 6 times over 30 seconds, else fail
-	for i := 1; i <= 6; i++ {
-		db, err = sql.Open("mysql", dsn)
+// Ping until the database answers, sleeping with backoff between tries
+func waitForDatabase(ctx context.Context, db *sql.DB, retry Retry) error {
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
 		if err == nil {
-			err = db.Ping()
-			if err == nil {
-				log.Println("Connected to database!")
-				return db, nil
-			}
+			return nil
+		}
+		delay := retry.Delay(attempt)
+		slog.WarnContext(ctx, "Cannot connect to database, retrying", "attempt", attempt+1, "error", err, "delay", delay.Round(time.Millisecond))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("could not connect to database: %w", err)
+		case <-timer.C:
 		}
-
-		log.Printf("Cannot connect to database, try %d/6: Waiting for database...", i)
-		time.Sleep(5*time.Second)
-	}
-
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Could not connect to database: %v", err)
-		return db, err
 	}
-
-	return db, err
 }
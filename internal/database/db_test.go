@@ -1,9 +1,12 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"testing"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
 )
 
 // Simple tests to see if database works
@@ -13,8 +16,18 @@ type Country struct {
 	COUNTRY_NAME        string `json:"countryName"`
 }
 
+func connect() (*sql.DB, error) {
+	conf, err := config.Load([]string{"-import-policy", config.ImportNever})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), conf.DATABASE.CONNECT_TIMEOUT)
+	defer cancel()
+	return Connect(ctx, conf.DATABASE)
+}
+
 func TestExistingQuery(t *testing.T) {
-	db, err := Connect()
+	db, err := connect()
 	if err != nil {
 		log.Println(err)
 	}
@@ -32,7 +45,7 @@ func TestExistingQuery(t *testing.T) {
 }
 
 func TestNotExistingQuery(t *testing.T) {
-	db, err := Connect()
+	db, err := connect()
 	if err != nil {
 		log.Println(err)
 	}
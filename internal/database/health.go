@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+)
+
+// Exponential backoff between connection tries, every delay is cut by up to half at random
+// so instances started together don't retry together
+type Retry struct {
+	INITIAL time.Duration
+	MAX     time.Duration
+}
+
+func RetryOf(conf config.Database) Retry {
+	return Retry{INITIAL: conf.RETRY_INITIAL, MAX: conf.RETRY_MAX}
+}
+
+// Delay after failed try number attempt counted from 0, doubles from INITIAL up to MAX
+func (r Retry) Delay(attempt int) time.Duration {
+	delay := r.INITIAL
+	for i := 0; i < attempt && delay < r.MAX; i++ {
+		delay *= 2
+	}
+	if delay > r.MAX {
+		delay = r.MAX
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// Database connection state seen by the monitor
+type Status struct {
+	UP    bool      `json:"up"`
+	SINCE time.Time `json:"since"`
+	ERROR string    `json:"error,omitempty"`
+}
+
+// Pings the database in background. A lost connection doesn't stop the server, requests fail until
+// the pool connects again and the status tells whether the database is reachable
+type Monitor struct {
+	db       *sql.DB
+	interval time.Duration
+	retry    Retry
+	now      func() time.Time
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Monitor of a connected database, status starts as up
+func NewMonitor(db *sql.DB, interval time.Duration, retry Retry) *Monitor {
+	m := &Monitor{db: db, interval: interval, retry: retry, now: time.Now}
+	m.status = Status{UP: true, SINCE: m.now()}
+	return m
+}
+
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Ping every interval until ctx is done, while the database is down pings follow the retry backoff
+func (m *Monitor) Run(ctx context.Context) {
+	failures := 0
+	for {
+		delay := m.interval
+		if failures > 0 {
+			delay = m.retry.Delay(failures - 1)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if m.check(ctx) {
+			failures = 0
+		} else {
+			failures++
+		}
+	}
+}
+
+// Ping once and record the result, state changes are logged
+func (m *Monitor) check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, m.interval)
+	defer cancel()
+	err := m.db.PingContext(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	switch {
+	case err != nil && m.status.UP:
+		slog.Error("Lost connection to database", "error", err)
+		m.status = Status{UP: false, SINCE: now, ERROR: err.Error()}
+	case err != nil:
+		m.status.ERROR = err.Error()
+	case !m.status.UP:
+		slog.Info("Reconnected to database", "downtime", now.Sub(m.status.SINCE).Round(time.Second))
+		m.status = Status{UP: true, SINCE: now}
+	}
+	return err == nil
+}
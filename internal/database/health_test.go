@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryDelay(t *testing.T) {
+	retry := Retry{INITIAL: 100 * time.Millisecond, MAX: time.Second}
+	for attempt, longest := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		longest *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			delay := retry.Delay(attempt)
+			if delay < longest/2 || delay > longest {
+				t.Fatalf("Delay(%d) = %s, expected between %s and %s", attempt, delay, longest/2, longest)
+			}
+		}
+	}
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	retry := Retry{INITIAL: time.Millisecond, MAX: 2 * time.Millisecond}
+
+	// Test 1: Database answers after a few tries
+	t.Run("Retry", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+		mock.ExpectPing()
+
+		assert.NoError(t, waitForDatabase(context.Background(), db, retry))
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Mock expectations were not met: %v", err)
+		}
+	})
+
+	// Test 2: Deadline ends waiting with the last error
+	t.Run("Deadline", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		if err != nil {
+			t.Fatalf("Failed to create mock database: %v", err)
+		}
+		defer db.Close()
+
+		for i := 0; i < 1000; i++ {
+			mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err = waitForDatabase(ctx, db, retry)
+		assert.ErrorContains(t, err, "could not connect to database")
+	})
+}
+
+func TestMonitor(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	monitor := NewMonitor(db, time.Second, Retry{INITIAL: time.Millisecond, MAX: time.Millisecond})
+	monitor.now = func() time.Time { return now }
+	monitor.status.SINCE = now
+	assert.True(t, monitor.Status().UP)
+
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	now = now.Add(time.Minute)
+	assert.False(t, monitor.check(context.Background()))
+	assert.Equal(t, Status{UP: false, SINCE: now, ERROR: "connection refused"}, monitor.Status())
+
+	mock.ExpectPing().WillReturnError(errors.New("no route to host"))
+	down := now
+	now = now.Add(time.Minute)
+	monitor.check(context.Background())
+	assert.Equal(t, Status{UP: false, SINCE: down, ERROR: "no route to host"}, monitor.Status(), "Down time should start at the first failure")
+
+	mock.ExpectPing()
+	now = now.Add(time.Minute)
+	assert.True(t, monitor.check(context.Background()))
+	assert.Equal(t, Status{UP: true, SINCE: now}, monitor.Status())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
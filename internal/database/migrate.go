@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// Schema changes on top of initdb/init.sql, applied in order on startup.
+// Never edit or reorder released entries, append new ones.
+var migrations = []string{
+	// 1: soft delete of branches
+	`ALTER TABLE branches
+		ADD COLUMN deleted_at DATETIME NULL DEFAULT NULL,
+		ADD KEY deleted_at_idx (deleted_at)`,
+	// 2: audit log of data changes
+	`CREATE TABLE audit_log (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		entity VARCHAR(16) NOT NULL,
+		entity_key VARCHAR(11) NOT NULL,
+		action VARCHAR(16) NOT NULL,
+		actor VARCHAR(255) NOT NULL,
+		source VARCHAR(16) NOT NULL,
+		before_value JSON NULL,
+		after_value JSON NULL,
+		created_at DATETIME(6) NOT NULL,
+		PRIMARY KEY (id),
+		KEY entity_key_idx (entity_key),
+		KEY actor_idx (actor),
+		KEY created_at_idx (created_at)
+	)`,
+	// 3: versioned branch rows for as of queries
+	`CREATE TABLE branch_versions (
+		swift_code VARCHAR(11) NOT NULL,
+		version INT NOT NULL,
+		address VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		town_name VARCHAR(255) DEFAULT NULL,
+		country_iso2 VARCHAR(2) NOT NULL,
+		country_name VARCHAR(255) NOT NULL,
+		is_headquarter TINYINT NOT NULL,
+		valid_from DATETIME(6) NOT NULL,
+		valid_to DATETIME(6) NULL DEFAULT NULL,
+		PRIMARY KEY (swift_code, version),
+		KEY country_valid_idx (country_iso2, valid_from)
+	)`,
+	// 4: branches stored before versioning are valid from the upgrade on
+	`INSERT INTO branch_versions (swift_code, version, address, name, town_name, country_iso2, country_name, is_headquarter, valid_from)
+	SELECT swift_code, 1, address, name, town_name, branches.country_iso2, country_name, is_headquarter, UTC_TIMESTAMP(6)
+	FROM branches
+	INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+	WHERE deleted_at IS NULL`,
+	// 5: API keys managed through the admin endpoint, only hashes are stored
+	`CREATE TABLE api_keys (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		name VARCHAR(255) NOT NULL,
+		key_hash CHAR(64) NOT NULL,
+		prefix VARCHAR(16) NOT NULL,
+		role VARCHAR(16) NOT NULL,
+		scopes VARCHAR(255) NOT NULL DEFAULT '',
+		created_by VARCHAR(255) NOT NULL,
+		created_at DATETIME(6) NOT NULL,
+		revoked_at DATETIME(6) NULL DEFAULT NULL,
+		PRIMARY KEY (id),
+		UNIQUE KEY key_hash_UNIQUE (key_hash)
+	)`,
+	// 6: per country data version for ETags of country responses
+	`ALTER TABLE countries
+		ADD COLUMN data_version BIGINT NOT NULL DEFAULT 1,
+		ADD COLUMN data_modified_at DATETIME(6) NULL DEFAULT NULL`,
+	// 7: countries stored before data versions count as modified at the upgrade
+	`UPDATE countries SET data_modified_at = UTC_TIMESTAMP(6)`,
+	// 8: row version of branches for optimistic concurrency, sent as ETag
+	`ALTER TABLE branches ADD COLUMN row_version BIGINT NOT NULL DEFAULT 1`,
+}
+
+// Schema version the code expects, number of known migrations
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// Read schema version stored in database, 0 if no migration was applied yet
+func CurrentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+// Apply all migrations newer than the stored schema version
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+		version INT NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (version)
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	version, err := CurrentVersion(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := db.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+		if _, err := db.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES (?)`, i+1); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", i+1, err)
+		}
+		slog.InfoContext(ctx, "Applied database migration", "version", i+1)
+	}
+	return nil
+}
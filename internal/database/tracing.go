@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/tracing"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Storage calls get a span when they run inside a traced request or job, pings of the monitor don't.
+// Spans are named after the statement, the query text only has placeholders and parameters are never recorded
+var traceOptions = []otelsql.Option{
+	otelsql.WithAttributes(semconv.DBSystemMySQL),
+	otelsql.WithSpanNameFormatter(spanName),
+	otelsql.WithAttributesGetter(func(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) []attribute.KeyValue {
+		if operation, _, _ := strings.Cut(statementName(query), " "); operation != "" {
+			return []attribute.KeyValue{semconv.DBOperation(operation)}
+		}
+		return nil
+	}),
+	otelsql.WithSpanOptions(otelsql.SpanOptions{
+		DisableErrSkip:       true,
+		OmitConnResetSession: true,
+		OmitRows:             true,
+		SpanFilter:           traced,
+	}),
+}
+
+// Only calls with a parent span are traced, unless the caller left them out with tracing.Untraced. The driver sends calls with parameters as prepared statements,
+// so their direct query and exec tries are skipped, only the prepare and the statement show up
+func traced(ctx context.Context, method otelsql.Method, query string, args []driver.NamedValue) bool {
+	if !trace.SpanContextFromContext(ctx).IsValid() || tracing.IsUntraced(ctx) {
+		return false
+	}
+	return len(args) == 0 || (method != otelsql.MethodConnQuery && method != otelsql.MethodConnExec)
+}
+
+func spanName(ctx context.Context, method otelsql.Method, query string) string {
+	name := statementName(query)
+	switch {
+	case name == "":
+		return string(method)
+	case method == otelsql.MethodConnPrepare:
+		return "PREPARE " + name
+	}
+	return name
+}
+
+// Statement name from the first keyword and the table it works on, like "SELECT branches" or "INSERT audit_log".
+// Empty for calls without a query like commits
+func statementName(query string) string {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return ""
+	}
+	operation := strings.ToUpper(words[0])
+	var before string
+	switch operation {
+	case "SELECT", "DELETE":
+		before = "FROM"
+	case "INSERT", "REPLACE":
+		before = "INTO"
+	case "UPDATE":
+		return strings.TrimSpace(operation + " " + table(words[1:], 0))
+	default:
+		return operation
+	}
+	for i, word := range words[1:] {
+		if strings.EqualFold(word, before) {
+			return strings.TrimSpace(operation + " " + table(words[1:], i+1))
+		}
+	}
+	return operation
+}
+
+// Table name at words[i], empty for subqueries
+func table(words []string, i int) string {
+	if i >= len(words) || strings.HasPrefix(words[i], "(") {
+		return ""
+	}
+	return strings.Trim(words[i], "`;,")
+}
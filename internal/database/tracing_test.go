@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/tracing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XSAM/otelsql"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStatementName(t *testing.T) {
+	for query, expected := range map[string]string{
+		"SELECT address, name FROM branches \n\tINNER JOIN countries ON branches.country_iso2 = countries.country_iso2": "SELECT branches",
+		"select count(*) from `audit_log` WHERE entity = ?":                                                             "SELECT audit_log",
+		"INSERT INTO branch_versions (swift_code) VALUES (?)":                                                           "INSERT branch_versions",
+		"UPDATE countries SET data_version = data_version + 1":                                                          "UPDATE countries",
+		"DELETE FROM branches WHERE deleted_at < ?":                                                                     "DELETE branches",
+		"SELECT COUNT(*) FROM (SELECT 1 FROM branches) AS t":                                                            "SELECT",
+		"CREATE TABLE IF NOT EXISTS schema_version (version INT)":                                                       "CREATE",
+		"": "",
+	} {
+		assert.Equal(t, expected, statementName(query), query)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	_, mock, err := sqlmock.NewWithDSN("tracing_test")
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	options := append([]otelsql.Option{otelsql.WithTracerProvider(provider)}, traceOptions...)
+	db, err := otelsql.Open("sqlmock", "tracing_test", options...)
+	if err != nil {
+		t.Fatalf("Failed to open traced database: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	// Test 1: Calls outside of a trace get no span
+	var count int
+	assert.NoError(t, db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM branches").Scan(&count))
+	assert.Empty(t, recorder.Ended())
+
+	// Test 2: Calls inside a trace are named after the statement
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM branches").Scan(&count))
+	parent.End()
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	assert.Equal(t, []string{"SELECT branches", "request"}, names)
+	assert.Contains(t, recorder.Ended()[0].Attributes(), semconv.DBOperation("SELECT"))
+
+	// Test 3: The driver prepares calls with parameters, so only the prepare and the statement get spans
+	assert.False(t, traced(ctx, otelsql.MethodConnQuery, "SELECT 1", []driver.NamedValue{{Ordinal: 1, Value: "AAAAPLPWXXX"}}))
+	assert.True(t, traced(ctx, otelsql.MethodStmtQuery, "SELECT 1", []driver.NamedValue{{Ordinal: 1, Value: "AAAAPLPWXXX"}}))
+
+	// Test 4: Untraced calls get no span but keep the parent, so their log lines still carry the trace ID
+	untraced := tracing.Untraced(ctx)
+	assert.False(t, traced(untraced, otelsql.MethodStmtExec, "INSERT INTO branches (swift_code) VALUES (?)", nil))
+	assert.Equal(t, parent.SpanContext(), trace.SpanContextFromContext(untraced))
+}
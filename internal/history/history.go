@@ -0,0 +1,109 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Stored values of a branch between VALID_FROM and VALID_TO, open versions have no VALID_TO
+type Version struct {
+	VERSION             int        `json:"version"`
+	ADDRESS             string     `json:"address"`
+	NAME                string     `json:"bankName"`
+	TOWN_NAME           string     `json:"townName,omitempty"`
+	COUNTRY_ISO2_CODEID string     `json:"countryISO2"`
+	COUNTRY_NAME        string     `json:"countryName"`
+	IS_HEADQUARTER      bool       `json:"isHeadquarter"`
+	SWIFT_CODE          string     `json:"swiftCode"`
+	VALID_FROM          time.Time  `json:"validFrom"`
+	VALID_TO            *time.Time `json:"validTo,omitempty"`
+}
+
+// Both *sql.DB and *sql.Tx, versions written in a transaction are rolled back with the change
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+const versionQuery = `
+	SELECT version, address, name, COALESCE(town_name, ''), country_iso2, country_name, is_headquarter, swift_code, valid_from, valid_to
+	FROM branch_versions`
+
+// Version valid at time at, half open so a version closed at exactly at is no longer valid
+const validAt = ` valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)`
+
+// Start a new version from the branch currently stored in branches table
+func Open(ctx context.Context, ex Execer, swift string, at time.Time) error {
+	_, err := ex.ExecContext(ctx, `
+	INSERT INTO branch_versions (swift_code, version, address, name, town_name, country_iso2, country_name, is_headquarter, valid_from)
+	SELECT swift_code, (SELECT COALESCE(MAX(version), 0) + 1 FROM branch_versions WHERE swift_code = ?),
+		address, name, town_name, branches.country_iso2, country_name, is_headquarter, ?
+	FROM branches
+	INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+	WHERE swift_code = ?`, swift, at.UTC(), swift)
+	return err
+}
+
+// End the open version of a branch, if there is one
+func Close(ctx context.Context, ex Execer, swift string, at time.Time) error {
+	_, err := ex.ExecContext(ctx, `UPDATE branch_versions SET valid_to = ? WHERE swift_code = ? AND valid_to IS NULL`, at.UTC(), swift)
+	return err
+}
+
+// Bump the data version of a country after any of its branches changed, country ETags derive from it
+func TouchCountry(ctx context.Context, ex Execer, iso2 string, at time.Time) error {
+	_, err := ex.ExecContext(ctx, `UPDATE countries SET data_version = data_version + 1, data_modified_at = ? WHERE country_iso2 = ?`, at.UTC(), iso2)
+	return err
+}
+
+// All versions of a branch, oldest first
+func List(ctx context.Context, db *sql.DB, swift string) ([]Version, error) {
+	return query(ctx, db, versionQuery+` WHERE swift_code = ? ORDER BY version`, swift)
+}
+
+// Version of a branch valid at time at, returns sql.ErrNoRows if branch did not exist then
+func Find(ctx context.Context, db *sql.DB, swift string, at time.Time) (Version, error) {
+	versions, err := query(ctx, db, versionQuery+` WHERE swift_code = ? AND`+validAt, swift, at.UTC(), at.UTC())
+	if err != nil {
+		return Version{}, err
+	}
+	if len(versions) == 0 {
+		return Version{}, sql.ErrNoRows
+	}
+	return versions[0], nil
+}
+
+// Branches under a headquarter valid at time at, prefix is the headquarter code without XXX
+func FindBranches(ctx context.Context, db *sql.DB, prefix string, at time.Time) ([]Version, error) {
+	return query(ctx, db, versionQuery+` WHERE swift_code LIKE ? AND swift_code NOT LIKE "%XXX" AND`+validAt+`
+	ORDER BY swift_code`, prefix+"%", at.UTC(), at.UTC())
+}
+
+// Branches of a country valid at time at
+func FindByCountry(ctx context.Context, db *sql.DB, iso2 string, at time.Time) ([]Version, error) {
+	return query(ctx, db, versionQuery+` WHERE country_iso2 = ? AND`+validAt+`
+	ORDER BY swift_code`, iso2, at.UTC(), at.UTC())
+}
+
+func query(ctx context.Context, db *sql.DB, query string, args ...any) ([]Version, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []Version
+	for rows.Next() {
+		var version Version
+		var validTo sql.NullTime
+		if err := rows.Scan(&version.VERSION, &version.ADDRESS, &version.NAME, &version.TOWN_NAME, &version.COUNTRY_ISO2_CODEID,
+			&version.COUNTRY_NAME, &version.IS_HEADQUARTER, &version.SWIFT_CODE, &version.VALID_FROM, &validTo); err != nil {
+			return nil, err
+		}
+		if validTo.Valid {
+			version.VALID_TO = &validTo.Time
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
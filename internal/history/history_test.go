@@ -0,0 +1,93 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var columns = []string{"version", "address", "name", "town_name", "country_iso2", "country_name", "is_headquarter", "swift_code", "valid_from", "valid_to"}
+
+func TestOpenClose(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("^UPDATE branch_versions SET valid_to = \\? WHERE swift_code = \\? AND valid_to IS NULL").
+		WithArgs(at, "ABCABCABCAB").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO branch_versions.*SELECT").
+		WithArgs("ABCABCABCAB", at, "ABCABCABCAB").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE countries SET data_version = data_version \\+ 1, data_modified_at = \\? WHERE country_iso2 = \\?").
+		WithArgs(at, "PL").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, Close(context.Background(), db, "ABCABCABCAB", at), "Close should not return an error")
+	assert.NoError(t, Open(context.Background(), db, "ABCABCABCAB", at), "Open should not return an error")
+	assert.NoError(t, TouchCountry(context.Background(), db, "PL", at), "TouchCountry should not return an error")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	validFrom := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	validTo := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? AND valid_from <= \\? AND \\(valid_to IS NULL OR valid_to > \\?\\)").
+		WithArgs("ABCABCABCAB", at, at).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "Main Street", "ABC BANK", "WARSAW", "PL", "POLAND", false, "ABCABCABCAB", validFrom, validTo))
+	mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\?").
+		WithArgs("DEFDEFDEFDE", at, at).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	version, err := Find(context.Background(), db, "ABCABCABCAB", at)
+	assert.NoError(t, err, "Find should not return an error")
+	assert.Equal(t, 2, version.VERSION)
+	assert.Equal(t, "Main Street", version.ADDRESS)
+	assert.Equal(t, validTo, *version.VALID_TO)
+
+	_, err = Find(context.Background(), db, "DEFDEFDEFDE", at)
+	assert.Equal(t, sql.ErrNoRows, err, "Branch without valid version should not be found")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	first := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM branch_versions WHERE swift_code = \\? ORDER BY version").
+		WithArgs("ABCABCABCAB").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "Old Street", "ABC BANK", "", "PL", "POLAND", false, "ABCABCABCAB", first, second).
+			AddRow(2, "Main Street", "ABC BANK", "", "PL", "POLAND", false, "ABCABCABCAB", second, nil))
+
+	versions, err := List(context.Background(), db, "ABCABCABCAB")
+	assert.NoError(t, err, "List should not return an error")
+	assert.Len(t, versions, 2)
+	assert.Nil(t, versions[1].VALID_TO, "Current version should be open")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
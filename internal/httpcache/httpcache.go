@@ -0,0 +1,83 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Set validators of a response and answer 304 Not Modified if the client's copy is current.
+// If-None-Match takes precedence over If-Modified-Since, zero modified time sends no Last-Modified
+func NotModified(c *gin.Context, etag string, modified time.Time) bool {
+	c.Header("ETag", etag)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if !ETagMatches(match, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		// Header has whole seconds only
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+	c.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// Check etag against a comma separated If-None-Match or If-Match header, * matches any tag
+func ETagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Gin middleware setting Cache-Control of successful and 304 responses, routes are keyed by "METHOD /path/:param"
+func CacheControl(routes map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := routes[c.Request.Method+" "+c.FullPath()]; ok {
+			c.Writer = &cacheControlWriter{c.Writer, value}
+		}
+		c.Next()
+	}
+}
+
+// Error responses must not be cached, so the header is only added once the status is known
+type cacheControlWriter struct {
+	gin.ResponseWriter
+	value string
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if code < http.StatusMultipleChoices || code == http.StatusNotModified {
+		w.Header().Set("Cache-Control", w.value)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Parse per route Cache-Control like "GET /v1/countries=public, max-age=300;GET /v1/banks=no-cache"
+func ParseCacheControl(value string) (map[string]string, error) {
+	routes := map[string]string{}
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		route, header, ok := strings.Cut(entry, "=")
+		if !ok || len(strings.Fields(route)) != 2 || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("wrong route Cache-Control %q, use METHOD /path=directives", entry)
+		}
+		routes[strings.Join(strings.Fields(route), " ")] = strings.TrimSpace(header)
+	}
+	return routes, nil
+}
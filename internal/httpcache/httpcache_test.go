@@ -0,0 +1,82 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var modified = time.Date(2026, 1, 1, 12, 0, 0, 500, time.UTC)
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(CacheControl(map[string]string{"GET /countries/:code": "no-cache"}))
+	router.GET("/countries/:code", func(c *gin.Context) {
+		if c.Param("code") == "XX" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown country"})
+			return
+		}
+		if NotModified(c, `"PL-3"`, modified) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"countryISO2": "PL"})
+	})
+	return router
+}
+
+func get(router *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestNotModified(t *testing.T) {
+	router := newRouter()
+
+	resp := get(router, "/countries/PL", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"PL-3"`, resp.Header().Get("ETag"))
+	assert.Equal(t, "Thu, 01 Jan 2026 12:00:00 GMT", resp.Header().Get("Last-Modified"))
+	assert.Equal(t, "no-cache", resp.Header().Get("Cache-Control"))
+
+	resp = get(router, "/countries/PL", map[string]string{"If-None-Match": `"PL-2", W/"PL-3"`})
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+	assert.Empty(t, resp.Body.String())
+	assert.Equal(t, "no-cache", resp.Header().Get("Cache-Control"))
+
+	resp = get(router, "/countries/PL", map[string]string{"If-None-Match": `"PL-2"`})
+	assert.Equal(t, http.StatusOK, resp.Code, "Changed data should be sent again")
+
+	resp = get(router, "/countries/PL", map[string]string{"If-Modified-Since": "Thu, 01 Jan 2026 12:00:00 GMT"})
+	assert.Equal(t, http.StatusNotModified, resp.Code)
+
+	resp = get(router, "/countries/PL", map[string]string{"If-Modified-Since": "Thu, 01 Jan 2026 11:59:59 GMT"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = get(router, "/countries/PL", map[string]string{"If-None-Match": `"PL-2"`, "If-Modified-Since": "Thu, 01 Jan 2026 12:00:00 GMT"})
+	assert.Equal(t, http.StatusOK, resp.Code, "If-None-Match takes precedence")
+}
+
+func TestCacheControlErrors(t *testing.T) {
+	resp := get(newRouter(), "/countries/XX", nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Empty(t, resp.Header().Get("Cache-Control"), "Errors should not be cached")
+}
+
+func TestParseCacheControl(t *testing.T) {
+	routes, err := ParseCacheControl("GET /v1/countries=public, max-age=300; GET  /v1/banks = no-cache")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"GET /v1/countries": "public, max-age=300", "GET /v1/banks": "no-cache"}, routes)
+
+	_, err = ParseCacheControl("/v1/countries=no-cache")
+	assert.Error(t, err)
+}
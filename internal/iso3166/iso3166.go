@@ -0,0 +1,158 @@
+package iso3166
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"slices"
+	"strings"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/textnorm"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// ISO 3166-1 country list, taken from the Debian iso-codes project
+//
+//go:embed iso3166-1.csv
+var data string
+
+type Country struct {
+	ALPHA2        string `json:"alpha2"`
+	ALPHA3        string `json:"alpha3"`
+	NUMERIC       string `json:"numeric"`
+	NAME          string `json:"shortName"`
+	OFFICIAL_NAME string `json:"officialName,omitempty"`
+	COMMON_NAME   string `json:"commonName,omitempty"`
+	LOCAL_NAME    string `json:"localName,omitempty"`
+}
+
+var ErrUnsupportedLanguage = errors.New("no country names in this language")
+
+// SWIFT uses user-assigned XK for Kosovo, which is not part of ISO 3166-1
+var userAssigned = []Country{
+	{ALPHA2: "XK", ALPHA3: "XKX", NAME: "Kosovo", OFFICIAL_NAME: "Republic of Kosovo"},
+}
+
+var (
+	countries []Country
+	byCode    = map[string]Country{} // alpha-2, alpha-3 and numeric codes
+	byName    = map[string]Country{} // folded short, official and common names
+)
+
+func init() {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		panic("iso3166: broken embedded data: " + err.Error())
+	}
+
+	for i, record := range records {
+		if i == 0 {
+			continue // Skip header
+		}
+		countries = append(countries, Country{
+			ALPHA2:        record[0],
+			ALPHA3:        record[1],
+			NUMERIC:       record[2],
+			NAME:          record[3],
+			OFFICIAL_NAME: record[4],
+			COMMON_NAME:   record[5],
+		})
+	}
+	countries = append(countries, userAssigned...)
+	slices.SortFunc(countries, func(a, b Country) int { return cmp.Compare(a.ALPHA2, b.ALPHA2) })
+
+	for _, country := range countries {
+		for _, code := range []string{country.ALPHA2, country.ALPHA3, country.NUMERIC} {
+			if code != "" {
+				byCode[code] = country
+			}
+		}
+		for _, name := range country.names() {
+			byName[foldName(name)] = country
+		}
+	}
+}
+
+// All countries ordered by alpha-2 code
+func All() []Country {
+	return append([]Country(nil), countries...)
+}
+
+// Lookup finds a country by its alpha-2, alpha-3 or numeric code, letter codes are case insensitive
+func Lookup(code string) (Country, bool) {
+	country, ok := byCode[strings.ToUpper(strings.TrimSpace(code))]
+	return country, ok
+}
+
+// LookupName finds a country by its short, official or common name, ignoring case, accents and punctuation
+func LookupName(name string) (Country, bool) {
+	country, ok := byName[foldName(name)]
+	return country, ok
+}
+
+// MatchesName reports if name is one of the country's names, ignoring case, accents and punctuation
+func (c Country) MatchesName(name string) bool {
+	folded := foldName(name)
+	for _, countryName := range c.names() {
+		if foldName(countryName) == folded {
+			return true
+		}
+	}
+	return false
+}
+
+// Name in the uppercase format used by the SWIFT CSV file, e.g. "POLAND"
+func (c Country) UpperName() string {
+	return strings.ToUpper(c.NAME)
+}
+
+// Language of localized names from a BCP 47 tag like "de" or "pt-BR", empty lang is language.Und
+func ParseLanguage(lang string) (language.Tag, error) {
+	if lang == "" {
+		return language.Und, nil
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return language.Und, err
+	}
+	if display.Regions(tag) == nil {
+		return language.Und, ErrUnsupportedLanguage
+	}
+	return tag, nil
+}
+
+// Country with its name in lang from CLDR data, unchanged for language.Und
+func (c Country) Localize(lang language.Tag) Country {
+	if lang == language.Und {
+		return c
+	}
+	namer := display.Regions(lang)
+	region, err := language.ParseRegion(c.ALPHA2)
+	if namer == nil || err != nil {
+		return c
+	}
+	c.LOCAL_NAME = namer.Name(region)
+	return c
+}
+
+func (c Country) names() []string {
+	names := []string{c.NAME}
+	if c.OFFICIAL_NAME != "" {
+		names = append(names, c.OFFICIAL_NAME)
+	}
+	if c.COMMON_NAME != "" {
+		names = append(names, c.COMMON_NAME)
+	}
+	// "Korea, Republic of" is also written as "Republic of Korea"
+	if before, after, ok := strings.Cut(c.NAME, ", "); ok {
+		names = append(names, after+" "+before)
+	}
+	return names
+}
+
+func foldName(name string) string {
+	return strings.Join(textnorm.Tokenize(name), " ")
+}
@@ -0,0 +1,86 @@
+package iso3166
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	for _, code := range []string{"PL", "pl", "POL", "616"} {
+		country, ok := Lookup(code)
+		assert.True(t, ok, "Lookup(%q) should find Poland", code)
+		assert.Equal(t, "PL", country.ALPHA2)
+		assert.Equal(t, "Poland", country.NAME)
+		assert.Equal(t, "POLAND", country.UpperName())
+	}
+
+	_, ok := Lookup("QQ")
+	assert.False(t, ok)
+
+	country, ok := Lookup("XK")
+	assert.True(t, ok, "Kosovo is used by SWIFT")
+	assert.Equal(t, "Kosovo", country.NAME)
+
+	assert.Len(t, All(), 250)
+}
+
+func TestAll(t *testing.T) {
+	all := All()
+	assert.True(t, slices.IsSortedFunc(all, func(a, b Country) int { return cmp.Compare(a.ALPHA2, b.ALPHA2) }),
+		"Countries should be ordered by alpha-2 code")
+
+	kosovo := slices.IndexFunc(all, func(c Country) bool { return c.ALPHA2 == "XK" })
+	assert.Equal(t, "WS", all[kosovo-1].ALPHA2, "User-assigned Kosovo should be sorted in, not appended")
+	assert.Equal(t, "YE", all[kosovo+1].ALPHA2)
+}
+
+func TestLookupName(t *testing.T) {
+	for _, name := range []string{"POLAND", "Republic of Poland", "republic of  poland"} {
+		country, ok := LookupName(name)
+		assert.True(t, ok, "LookupName(%q) should find Poland", name)
+		assert.Equal(t, "PL", country.ALPHA2)
+	}
+
+	country, ok := LookupName("Cote d'Ivoire")
+	assert.True(t, ok)
+	assert.Equal(t, "CI", country.ALPHA2)
+
+	country, ok = LookupName("Republic of Korea")
+	assert.True(t, ok)
+	assert.Equal(t, "KR", country.ALPHA2)
+
+	_, ok = LookupName("Atlantis")
+	assert.False(t, ok)
+}
+
+func TestMatchesName(t *testing.T) {
+	country, _ := Lookup("US")
+	assert.True(t, country.MatchesName("UNITED STATES"))
+	assert.True(t, country.MatchesName("United States of America"))
+	assert.False(t, country.MatchesName("USA"))
+}
+
+func TestLocalize(t *testing.T) {
+	lang, err := ParseLanguage("de")
+	assert.NoError(t, err)
+	country, _ := Lookup("PL")
+	assert.Equal(t, "Polen", country.Localize(lang).LOCAL_NAME)
+	assert.Empty(t, country.LOCAL_NAME, "Localize returns a copy")
+
+	lang, err = ParseLanguage("pl")
+	assert.NoError(t, err)
+	country, _ = Lookup("XK")
+	assert.Equal(t, "Kosowo", country.Localize(lang).LOCAL_NAME)
+
+	lang, err = ParseLanguage("")
+	assert.NoError(t, err)
+	assert.Empty(t, country.Localize(lang).LOCAL_NAME)
+
+	_, err = ParseLanguage("xx")
+	assert.Error(t, err)
+	_, err = ParseLanguage("not a language")
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "warn")
+
+	logger.InfoContext(context.Background(), "Hidden")
+	logger.WarnContext(WithRequestID(context.Background(), "abc-123"), "Shown", "swiftCode", "AAAAPLPWXXX")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !assert.Len(t, lines, 1, "Info lines should be filtered at warn level") {
+		return
+	}
+	var line map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatalf("Log line is not JSON: %v", err)
+	}
+	assert.Equal(t, "Shown", line["msg"])
+	assert.Equal(t, "abc-123", line["requestId"])
+	assert.Equal(t, "AAAAPLPWXXX", line["swiftCode"])
+}
+
+func TestRequestIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestIDs())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c.Request.Context()))
+	})
+
+	get := func(id string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test 1: Id from the client is kept
+	t.Run("Client Id", func(t *testing.T) {
+		resp := get("req-42")
+		assert.Equal(t, "req-42", resp.Header().Get(RequestIDHeader))
+		assert.Equal(t, "req-42", resp.Body.String())
+	})
+
+	// Test 2: Missing or unsafe ids are generated
+	t.Run("Generated Id", func(t *testing.T) {
+		for _, id := range []string{"", "bad id\n", strings.Repeat("a", 200)} {
+			resp := get(id)
+			generated := resp.Header().Get(RequestIDHeader)
+			assert.Len(t, generated, 32)
+			assert.Equal(t, generated, resp.Body.String())
+		}
+		assert.NotEqual(t, get("").Body.String(), get("").Body.String(), "Generated ids should differ")
+	})
+}
+
+func TestTraceIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "info")
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	logger.InfoContext(ctx, "Traced")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Log line is not JSON: %v", err)
+	}
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["traceId"])
+	assert.Equal(t, "00f067aa0ba902b7", line["spanId"])
+}
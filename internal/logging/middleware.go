@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// Longest request id taken from clients, longer ones are replaced
+const maxRequestIDLength = 128
+
+// Take the request id from X-Request-ID or generate one, store it in the request context and send it back.
+// Register first so every later middleware and handler logs with it
+func RequestIDs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// One info line per request, requests to skipPaths are not logged
+func AccessLog(skipPaths []string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+		if skip[path] {
+			return
+		}
+		slog.InfoContext(c.Request.Context(), "Request",
+			"method", c.Request.Method,
+			"path", path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+			"clientIp", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		)
+	}
+}
+
+// Ids from clients are kept when short and made of letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/cache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prefix of the server's own metrics
+const namespace = "swift"
+
+// Route label of requests that matched no route, keeps unknown paths from growing the label set
+const unmatchedRoute = "unmatched"
+
+// Import outcomes
+const (
+	ImportSucceeded = "success"
+	ImportFailed    = "failure"
+)
+
+// Collectors of one server, exposed in Prometheus text format by Handler
+type Metrics struct {
+	registry   *prometheus.Registry
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	imports    *prometheus.CounterVec
+	importRows *prometheus.CounterVec
+}
+
+// Metrics with request and import collectors, Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		imports: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "imports_total",
+			Help:      "CSV imports by result.",
+		}, []string{"result"}),
+		importRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "import_rows_total",
+			Help:      "Branch rows handled by CSV imports, by outcome.",
+		}, []string{"outcome"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.duration, m.imports, m.importRows,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Count and time every request by its route pattern, register before other middleware so rejected requests count too
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		// Recorded when the handler panicked too, as the 500 the recovery middleware answers with. The panic goes on to it
+		defer func() {
+			status := c.Writer.Status()
+			recovered := recover()
+			if recovered != nil {
+				status = http.StatusInternalServerError
+			}
+
+			route := c.FullPath()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			method := c.Request.Method
+			m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+			m.duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// Connection pool metrics from sql.DBStats, labeled with name
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Response cache lookups and hit ratio, stats is read on every scrape
+func (m *Metrics) RegisterCache(stats func() cache.Stats) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hits_total",
+			Help:      "Responses served from the response cache.",
+		}, func() float64 { return float64(stats().HITS) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_misses_total",
+			Help:      "Response cache lookups that found nothing.",
+		}, func() float64 { return float64(stats().MISSES) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_entries",
+			Help:      "Responses stored in the response cache.",
+		}, func() float64 { return float64(stats().ENTRIES) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_hit_ratio",
+			Help:      "Share of response cache lookups served from cache since startup.",
+		}, func() float64 { return stats().HitRatio() }),
+	)
+}
+
+// Record a finished import with its branch row counts
+func (m *Metrics) ObserveImport(result string, inserted, duplicates, rejected int) {
+	m.imports.WithLabelValues(result).Inc()
+	m.importRows.WithLabelValues("inserted").Add(float64(inserted))
+	m.importRows.WithLabelValues("duplicate").Add(float64(duplicates))
+	m.importRows.WithLabelValues("rejected").Add(float64(rejected))
+}
+
+// Prometheus text exposition of all registered metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
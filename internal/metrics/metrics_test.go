@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/cache"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	resp := httptest.NewRecorder()
+	m.Handler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: got %v, want %v", resp.Code, http.StatusOK)
+	}
+	return resp.Body.String()
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/v1/swift-codes/:swift-code", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"swiftCode": c.Param("swift-code")})
+	})
+
+	for _, path := range []string{"/v1/swift-codes/AAAAPLPWXXX", "/v1/swift-codes/BBBBPLPWXXX", "/unknown"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `swift_http_requests_total{code="200",method="GET",route="/v1/swift-codes/:swift-code"} 2`, "Requests should be labeled by route pattern")
+	assert.Contains(t, body, `swift_http_requests_total{code="404",method="GET",route="unmatched"} 1`)
+	assert.Contains(t, body, `swift_http_request_duration_seconds_count{method="GET",route="/v1/swift-codes/:swift-code"} 2`)
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	m := New()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery(), m.Middleware())
+	router.GET("/v1/banks", func(c *gin.Context) { panic("broken handler") })
+
+	req, _ := http.NewRequest(http.MethodGet, "/v1/banks", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Contains(t, scrape(t, m), `swift_http_requests_total{code="500",method="GET",route="/v1/banks"} 1`, "Recovered panics should be counted as 500")
+}
+
+func TestImportAndCache(t *testing.T) {
+	m := New()
+	m.ObserveImport(ImportSucceeded, 5, 2, 1)
+	m.ObserveImport(ImportFailed, 1, 0, 0)
+	m.RegisterCache(func() cache.Stats { return cache.Stats{HITS: 3, MISSES: 1, ENTRIES: 2} })
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `swift_imports_total{result="success"} 1`)
+	assert.Contains(t, body, `swift_imports_total{result="failure"} 1`)
+	assert.Contains(t, body, `swift_import_rows_total{outcome="inserted"} 6`)
+	assert.Contains(t, body, `swift_import_rows_total{outcome="duplicate"} 2`)
+	assert.Contains(t, body, `swift_import_rows_total{outcome="rejected"} 1`)
+	assert.Contains(t, body, "swift_cache_hits_total 3")
+	assert.Contains(t, body, "swift_cache_hit_ratio 0.75")
+}
+
+func TestRegisterDB(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(25)
+
+	m := New()
+	m.RegisterDB(db, "swift_db")
+
+	assert.Contains(t, scrape(t, m), `go_sql_max_open_connections{db_name="swift_db"} 25`)
+}
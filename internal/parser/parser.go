@@ -1,12 +1,18 @@
 package parser
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/audit"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/history"
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/iso3166"
 
 	"github.com/go-sql-driver/mysql"
 )
@@ -23,34 +29,45 @@ type Record struct {
 	IS_HEADQUARTER      bool   `json:"isHeadquarter"`
 }
 
+// Actor recorded in the audit log for rows written by the importer
+const importActor = "csv-import"
+
 type CountryRecord struct {
 	COUNTRY_ISO2_CODEID string `json:"countryISO2"`
 	COUNTRY_NAME        string `json:"countryName"`
 }
 
-// Both parse and insert data into database
-func Parse(db *sql.DB) error {
+// Branch rows of one import by outcome
+type Counts struct {
+	INSERTED   int `json:"inserted"`
+	DUPLICATES int `json:"duplicates"`
+	REJECTED   int `json:"rejected"`
+}
+
+// Both parse and insert data into database, counts cover the branch rows handled before an error
+func Parse(ctx context.Context, db *sql.DB, csvPath string) (Counts, error) {
 	// Open and parse CSV file
-	parsedRecords, err := ParseCSV("../../internal/data/SWIFT_CODES.csv")
+	parsedRecords, err := ParseCSV(csvPath)
 	if err != nil {
-		fmt.Printf("Failed to parseCSV: %v\n", err)
-		return err
+		slog.ErrorContext(ctx, "Failed to parse CSV", "path", csvPath, "error", err)
+		return Counts{}, err
 	}
 
 	// Insert countries first to avoid foreign key errors
-	err = InsertCountries(db, parsedRecords)
+	err = InsertCountries(ctx, db, parsedRecords)
 	if err != nil {
-		fmt.Printf("Failed to insert countries to database: %v\n", err)
-		return err
+		slog.ErrorContext(ctx, "Failed to insert countries to database", "error", err)
+		return Counts{}, err
 	}
 
 	// Insert branches to database
-	err = InsertBranches(db, parsedRecords)
+	counts, err := InsertBranches(ctx, db, parsedRecords)
 	if err != nil {
-		fmt.Printf("Failed to insert branches to database: %v\n", err)
-		return err
+		slog.ErrorContext(ctx, "Failed to insert branches to database", "error", err)
+		return counts, err
 	}
-	return nil
+	slog.InfoContext(ctx, "Imported branches", "inserted", counts.INSERTED, "duplicates", counts.DUPLICATES, "rejected", counts.REJECTED)
+	return counts, nil
 }
 
 func ParseCSV(filePath string) ([]Record, error) {
@@ -86,53 +103,117 @@ func ParseCSV(filePath string) ([]Record, error) {
 	return parsedRecords, nil
 }
 
-func InsertCountries(db *sql.DB, records []Record) error {
-	countrySet := map[string]CountryRecord{}
+func InsertCountries(ctx context.Context, db *sql.DB, records []Record) error {
+	// Deduplicate by ISO2 code, first name seen in the file wins unless ISO 3166 knows the code
+	var countries []CountryRecord
+	seen := map[string]bool{}
 
 	for _, record := range records {
-		if _, exists := countrySet[record.COUNTRY_NAME]; !exists {
-			countrySet[record.COUNTRY_NAME] = CountryRecord{
+		if !seen[record.COUNTRY_ISO2_CODEID] {
+			seen[record.COUNTRY_ISO2_CODEID] = true
+			countries = append(countries, CountryRecord{
 				COUNTRY_ISO2_CODEID: record.COUNTRY_ISO2_CODEID,
-				COUNTRY_NAME:        record.COUNTRY_NAME,
-			}
+				COUNTRY_NAME:        countryName(record.COUNTRY_ISO2_CODEID, record.COUNTRY_NAME),
+			})
 		}
 	}
 
-	for _, country := range countrySet {
-		if err := insertCountry(db, country); err != nil {
+	for _, country := range countries {
+		if err := insertCountry(ctx, db, country); err != nil {
 			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-				log.Print("Duplicate entry, ignoring error.")
+				slog.DebugContext(ctx, "Duplicate country, skipping", "countryISO2", country.COUNTRY_ISO2_CODEID)
 			} else {
-				log.Fatalf("Database error: %v", err)
+				return fmt.Errorf("database error: %w", err)
 			}
 		}
 	}
 	return nil
 }
 
-func InsertBranches(db *sql.DB, records []Record) error {
+// Countries known to ISO 3166 are stored under their uppercase ISO short name, like the API stores them
+func countryName(iso2, name string) string {
+	if iso, ok := iso3166.Lookup(iso2); ok && len(iso2) == 2 {
+		return iso.UpperName()
+	}
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// Rows the database refuses for their data are rejected and skipped, other errors stop the import
+func InsertBranches(ctx context.Context, db *sql.DB, records []Record) (Counts, error) {
+	var counts Counts
 	for _, record := range records {
-		if err := insertRecord(db, record); err != nil {
-			if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-				log.Print("Duplicate entry, ignoring error.")
-			} else {
-				log.Fatalf("Database error: %v", err)
-			}
+		err := insertRecord(ctx, db, record)
+		if err == nil {
+			counts.INSERTED++
+			continue
+		}
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			slog.DebugContext(ctx, "Duplicate branch, skipping", "swiftCode", record.SWIFT_CODE)
+			counts.DUPLICATES++
+		} else if ok {
+			slog.WarnContext(ctx, "Rejected branch", "swiftCode", record.SWIFT_CODE, "error", err)
+			counts.REJECTED++
+		} else {
+			return counts, fmt.Errorf("database error: %w", err)
 		}
 	}
-	return nil
+	return counts, nil
+}
+
+// Helper function to insert a record into the database, the branch, its first version and its audit entry
+// are written in one transaction so a failed step leaves nothing behind
+func insertRecord(ctx context.Context, db *sql.DB, record Record) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		query := `INSERT INTO branches (swift_code, name, town_name, address, time_zone, country_iso2, is_headquarter) VALUES (?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, query, record.SWIFT_CODE, record.NAME, record.TOWN_NAME, record.ADDRESS, record.TIME_ZONE, record.COUNTRY_ISO2_CODEID, strings.HasSuffix(record.SWIFT_CODE, "XXX"))
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := history.Open(ctx, tx, record.SWIFT_CODE, now); err != nil {
+			return err
+		}
+		if err := history.TouchCountry(ctx, tx, record.COUNTRY_ISO2_CODEID, now); err != nil {
+			return err
+		}
+		return recordImport(ctx, tx, audit.EntityBranch, record.SWIFT_CODE, record)
+	})
 }
 
-// Helper function to insert a record into the database
-func insertRecord(db *sql.DB, record Record) error {
-	query := `INSERT INTO branches (swift_code, name, town_name, address, time_zone, country_iso2, is_headquarter) VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := db.Exec(query, record.SWIFT_CODE, record.NAME, record.TOWN_NAME, record.ADDRESS, record.TIME_ZONE, record.COUNTRY_ISO2_CODEID, strings.HasSuffix(record.SWIFT_CODE, "XXX"))
-	return err
+// Helper function to insert a country with its audit entry into the database
+func insertCountry(ctx context.Context, db *sql.DB, record CountryRecord) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		query := `INSERT INTO countries (country_iso2, country_name) VALUES (?, ?)`
+		if _, err := tx.ExecContext(ctx, query, record.COUNTRY_ISO2_CODEID, record.COUNTRY_NAME); err != nil {
+			return err
+		}
+		return recordImport(ctx, tx, audit.EntityCountry, record.COUNTRY_ISO2_CODEID, record)
+	})
+}
+
+// Run fn in a transaction, committed when fn returns nil and rolled back otherwise
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			slog.ErrorContext(ctx, "Failed to roll back transaction", "error", rollbackErr)
+		}
+		return err
+	}
+	return tx.Commit()
 }
 
-// Helper function to insert a country into the database
-func insertCountry(db *sql.DB, record CountryRecord) error {
-	query := `INSERT INTO countries (country_iso2, country_name) VALUES (?, ?)`
-	_, err := db.Exec(query, record.COUNTRY_ISO2_CODEID, record.COUNTRY_NAME)
-	return err
+// Helper function to write an audit entry for an imported row
+func recordImport(ctx context.Context, ex audit.Execer, entity, key string, record any) error {
+	return audit.Record(ctx, ex, audit.Entry{
+		ENTITY: entity,
+		KEY:    key,
+		ACTION: audit.ActionCreate,
+		ACTOR:  importActor,
+		SOURCE: audit.SourceImport,
+		AFTER:  audit.Snapshot(record),
+	})
 }
@@ -1,10 +1,14 @@
 package parser
 
 import (
+	"context"
 	"os"
 	"testing"
 
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/audit"
+
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,18 +41,61 @@ func TestInsertCountries(t *testing.T) {
 	records := []Record{
 		{COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND"},
 		{COUNTRY_ISO2_CODEID: "US", COUNTRY_NAME: "USA"},
+		{COUNTRY_ISO2_CODEID: "QQ", COUNTRY_NAME: " Atlantis"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO countries.*").WithArgs("PL", "POLAND").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityCountry, "PL", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO countries.*").WithArgs("US", "UNITED STATES").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityCountry, "US", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// Codes missing from ISO 3166 keep the name from the file
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO countries.*").WithArgs("QQ", "ATLANTIS").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityCountry, "QQ", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = InsertCountries(context.Background(), db, records)
+	assert.NoError(t, err, "InsertCountries should not return an error")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestInsertCountriesDeduplicatesByCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	records := []Record{
+		{COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "POLAND"},
+		{COUNTRY_ISO2_CODEID: "PL", COUNTRY_NAME: "REPUBLIC OF POLAND"},
+		{COUNTRY_ISO2_CODEID: "US", COUNTRY_NAME: "USA"},
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO countries.*").WithArgs("PL", "POLAND").WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec("^INSERT INTO countries.*").WithArgs("US", "USA").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityCountry, "PL", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO countries.*").WithArgs("US", "UNITED STATES").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityCountry, "US", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	err = InsertCountries(db, records)
+	err = InsertCountries(context.Background(), db, records)
 	assert.NoError(t, err, "InsertCountries should not return an error")
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Mock expectations were not met: %v", err)
 	}
 }
+
 func TestInsertBranches(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -77,11 +124,63 @@ func TestInsertBranches(t *testing.T) {
 		},
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO branches.*").WithArgs("ABCABCABCAB", "ABC BANK", "Warsaw", "Main Street", "Europe/Warsaw", "PL", false).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO branch_versions.*").WithArgs("ABCABCABCAB", sqlmock.AnyArg(), "ABCABCABCAB").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE countries SET data_version.*").WithArgs(sqlmock.AnyArg(), "PL").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityBranch, "ABCABCABCAB", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
 	mock.ExpectExec("^INSERT INTO branches.*").WithArgs("DEFDEFDEFDEFXXX", "DEF BANK", "New York", "Wall Street", "America/New_York", "US", true).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO branch_versions.*").WithArgs("DEFDEFDEFDEFXXX", sqlmock.AnyArg(), "DEFDEFDEFDEFXXX").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE countries SET data_version.*").WithArgs(sqlmock.AnyArg(), "US").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WithArgs(audit.EntityBranch, "DEFDEFDEFDEFXXX", audit.ActionCreate, importActor, audit.SourceImport, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
-	err = InsertBranches(db, records)
+	counts, err := InsertBranches(context.Background(), db, records)
 	assert.NoError(t, err, "InsertBranches should not return an error")
+	assert.Equal(t, Counts{INSERTED: 2}, counts)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
+
+func TestInsertBranchesCounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	records := []Record{
+		{COUNTRY_ISO2_CODEID: "PL", SWIFT_CODE: "ABCABCABCAB"},
+		{COUNTRY_ISO2_CODEID: "XX", SWIFT_CODE: "GHIGHIGHXXX"},
+		{COUNTRY_ISO2_CODEID: "PL", SWIFT_CODE: "MNOMNOMNXXX"},
+		{COUNTRY_ISO2_CODEID: "PL", SWIFT_CODE: "JKLJKLJKXXX"},
+	}
+
+	// Duplicate and foreign key errors skip the row, a broken connection stops the import
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO branches.*").WithArgs("ABCABCABCAB", "", "", "", "", "PL", false).WillReturnError(&mysql.MySQLError{Number: 1062})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO branches.*").WithArgs("GHIGHIGHXXX", "", "", "", "", "XX", true).WillReturnError(&mysql.MySQLError{Number: 1452})
+	mock.ExpectRollback()
+	// Branch whose audit entry fails is rolled back, so the next import can still insert it
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO branches.*").WithArgs("MNOMNOMNXXX", "", "", "", "", "PL", true).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^INSERT INTO branch_versions.*").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("^UPDATE countries SET data_version.*").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO audit_log.*").WillReturnError(&mysql.MySQLError{Number: 1406})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO branches.*").WithArgs("JKLJKLJKXXX", "", "", "", "", "PL", true).WillReturnError(mysql.ErrInvalidConn)
+	mock.ExpectRollback()
+
+	counts, err := InsertBranches(context.Background(), db, records)
+	assert.ErrorIs(t, err, mysql.ErrInvalidConn)
+	assert.Equal(t, Counts{DUPLICATES: 1, REJECTED: 2}, counts)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Mock expectations were not met: %v", err)
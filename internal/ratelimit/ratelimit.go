@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Buckets idle for this long are full again and can be forgotten
+const idleAfter = 10 * time.Minute
+
+// RATE tokens per second refill a bucket of BURST tokens, one token per request
+type Limit struct {
+	RATE  float64
+	BURST int
+}
+
+// Default limit is shared by all routes without their own limit, routes are keyed by "METHOD /path/:param"
+type Limits struct {
+	DEFAULT Limit
+	ROUTES  map[string]Limit
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Token buckets of one limit, one bucket per client
+type Limiter struct {
+	limit   Limit
+	now     func() time.Time
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Take a token for client, returns tokens left, time until the bucket is full and,
+// when not allowed, time until a token is available
+func (l *Limiter) Allow(client string) (allowed bool, remaining int, reset, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(client)
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retryAfter = l.duration(1 - b.tokens)
+	}
+	return allowed, int(b.tokens), l.duration(float64(l.limit.BURST) - b.tokens), retryAfter
+}
+
+// Check if client has a token left without taking it, returns time until a token is available when not
+func (l *Limiter) Blocked(client string) (blocked bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.refill(client)
+	if b.tokens >= 1 {
+		return false, 0
+	}
+	return true, l.duration(1 - b.tokens)
+}
+
+// Bucket of client with the tokens refilled since its last request
+func (l *Limiter) refill(client string) *bucket {
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.BURST), last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(float64(l.limit.BURST), b.tokens+now.Sub(b.last).Seconds()*l.limit.RATE)
+	b.last = now
+	return b
+}
+
+// Time to refill tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.RATE * float64(time.Second))
+}
+
+// Forget idle clients once in a while so the map does not grow forever
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < idleAfter {
+		return
+	}
+	l.swept = now
+	for client, b := range l.buckets {
+		if now.Sub(b.last) > idleAfter {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// Gin middleware limiting requests per client, client returns the key of the request's client
+func Middleware(limits Limits, client func(c *gin.Context) string) gin.HandlerFunc {
+	var shared *Limiter
+	if limits.DEFAULT.RATE > 0 {
+		shared = NewLimiter(limits.DEFAULT)
+	}
+	routes := map[string]*Limiter{}
+	for route, limit := range limits.ROUTES {
+		routes[route] = NewLimiter(limit)
+	}
+
+	return func(c *gin.Context) {
+		limiter, ok := routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			limiter = shared
+		}
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		allowed, remaining, reset, retryAfter := limiter.Allow(client(c))
+		c.Header("RateLimit-Limit", strconv.Itoa(limiter.limit.BURST))
+		c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(reset)))
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(retryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": "Too many requests, retry in " + strconv.Itoa(seconds(retryAfter)) + " seconds", "requestId": logging.RequestID(c.Request.Context())})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Gin middleware limiting failed requests per client, only responses with status take a token.
+// Clients without tokens are rejected before the rest of the chain runs
+func FailureMiddleware(limit Limit, status int, client func(c *gin.Context) string) gin.HandlerFunc {
+	if limit.RATE <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limiter := NewLimiter(limit)
+	return func(c *gin.Context) {
+		key := client(c)
+		if blocked, retryAfter := limiter.Blocked(key); blocked {
+			c.Header("Retry-After", strconv.Itoa(seconds(retryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": "Too many failed requests, retry in " + strconv.Itoa(seconds(retryAfter)) + " seconds", "requestId": logging.RequestID(c.Request.Context())})
+			return
+		}
+		c.Next()
+		if c.Writer.Status() == status {
+			limiter.Allow(key)
+		}
+	}
+}
+
+// Headers carry whole seconds, rounded up so clients don't retry too early
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Parse a limit like 20/s, 600/m or 1000/h, optional :burst defaults to the count
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	count, unit, ok := strings.Cut(rate, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("wrong rate limit %q, use count/unit like 20/s", value)
+	}
+
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if per == 0 {
+		return Limit{}, fmt.Errorf("wrong rate limit unit in %q, use s, m or h", value)
+	}
+
+	limit := Limit{RATE: float64(n) / per.Seconds(), BURST: n}
+	if hasBurst {
+		if limit.BURST, err = strconv.Atoi(burst); err != nil || limit.BURST <= 0 {
+			return Limit{}, fmt.Errorf("wrong burst in rate limit %q", value)
+		}
+	}
+	return limit, nil
+}
+
+// Parse per route limits like "GET /v1/swift-codes/:swift-code=20/s,POST /v1/swift-codes/lookup=2/s:5"
+func ParseRoutes(value string) (map[string]Limit, error) {
+	routes := map[string]Limit{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		route, rate, ok := strings.Cut(entry, "=")
+		if !ok || len(strings.Fields(route)) != 2 {
+			return nil, fmt.Errorf("wrong route rate limit %q, use METHOD /path=count/unit", entry)
+		}
+		limit, err := ParseLimit(rate)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	return routes, nil
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{RATE: 1, BURST: 2})
+	l.now = func() time.Time { return now }
+
+	allowed, remaining, _, _ := l.Allow("alice")
+	assert.True(t, allowed)
+	assert.Equal(t, 1, remaining)
+
+	allowed, remaining, reset, _ := l.Allow("alice")
+	assert.True(t, allowed)
+	assert.Equal(t, 0, remaining)
+	assert.Equal(t, 2*time.Second, reset)
+
+	allowed, _, _, retryAfter := l.Allow("alice")
+	assert.False(t, allowed, "Empty bucket should reject")
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _, _, _ = l.Allow("bob")
+	assert.True(t, allowed, "Clients have separate buckets")
+
+	now = now.Add(time.Second)
+	allowed, _, _, _ = l.Allow("alice")
+	assert.True(t, allowed, "Bucket should refill over time")
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{RATE: 1, BURST: 2})
+	l.now = func() time.Time { return now }
+
+	l.Allow("alice")
+	now = now.Add(2 * idleAfter)
+	l.Allow("bob")
+
+	assert.Len(t, l.buckets, 1, "Idle client should be forgotten")
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes, err := ParseRoutes("GET /branches/:code=1/m")
+	if err != nil {
+		t.Fatalf("Failed to parse routes: %v", err)
+	}
+	router := gin.New()
+	router.Use(Middleware(Limits{DEFAULT: Limit{RATE: 10, BURST: 10}, ROUTES: routes}, func(c *gin.Context) string {
+		return c.GetHeader("X-Client")
+	}))
+	router.GET("/branches/:code", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/countries", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	get := func(path, client string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Client", client)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := get("/branches/ABC", "alice")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", resp.Header().Get("RateLimit-Remaining"))
+
+	// Route limit counts all paths of the route
+	resp = get("/branches/DEF", "alice")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "60", resp.Header().Get("Retry-After"))
+	assert.Contains(t, resp.Body.String(), "message")
+
+	resp = get("/branches/DEF", "bob")
+	assert.Equal(t, http.StatusOK, resp.Code, "Other client is not limited")
+
+	resp = get("/countries", "alice")
+	assert.Equal(t, http.StatusOK, resp.Code, "Routes without own limit use the default")
+	assert.Equal(t, "10", resp.Header().Get("RateLimit-Limit"))
+}
+
+func TestFailureMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.Use(FailureMiddleware(Limit{RATE: 1.0 / 60, BURST: 2}, http.StatusUnauthorized, func(c *gin.Context) string {
+		return c.GetHeader("X-Client")
+	}))
+	router.GET("/branches", func(c *gin.Context) {
+		calls++
+		if c.GetHeader("X-Key") != "secret" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	get := func(client, key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/branches", nil)
+		req.Header.Set("X-Client", client)
+		req.Header.Set("X-Key", key)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	assert.Equal(t, http.StatusOK, get("alice", "secret").Code)
+	assert.Equal(t, http.StatusOK, get("alice", "secret").Code)
+	assert.Equal(t, http.StatusOK, get("alice", "secret").Code, "Successful requests take no token")
+
+	assert.Equal(t, http.StatusUnauthorized, get("alice", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("alice", "wrong").Code)
+	calls = 0
+	resp := get("alice", "secret")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code, "Client out of tokens should be rejected")
+	assert.Equal(t, "60", resp.Header().Get("Retry-After"))
+	assert.Zero(t, calls, "Rejected request should not reach the handler")
+
+	assert.Equal(t, http.StatusUnauthorized, get("bob", "wrong").Code, "Other client is not limited")
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("600/m")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{RATE: 10, BURST: 600}, limit)
+
+	limit, err = ParseLimit("20/s:5")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{RATE: 20, BURST: 5}, limit)
+
+	for _, wrong := range []string{"20", "20/d", "-1/s", "20/s:0", "many/s"} {
+		_, err := ParseLimit(wrong)
+		assert.Error(t, err, wrong)
+	}
+}
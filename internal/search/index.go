@@ -0,0 +1,365 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/textnorm"
+)
+
+type Document struct {
+	ADDRESS             string `json:"address"`
+	NAME                string `json:"bankName"`
+	TOWN_NAME           string `json:"townName"`
+	COUNTRY_ISO2_CODEID string `json:"countryISO2"`
+	COUNTRY_NAME        string `json:"countryName"`
+	IS_HEADQUARTER      bool   `json:"isHeadquarter"`
+	SWIFT_CODE          string `json:"swiftCode"`
+}
+
+type Hit struct {
+	Document
+	SCORE float64 `json:"score"`
+}
+
+// Field weights used for ranking, a match in the SWIFT code counts the most
+const (
+	fieldCode    = 4
+	fieldName    = 3
+	fieldTown    = 2
+	fieldAddress = 1
+)
+
+// Match kind multipliers, exact token beats prefix beats fuzzy
+const (
+	matchExact  = 1.0
+	matchPrefix = 0.6
+	matchFuzzy  = 0.3
+)
+
+// In-memory inverted index over bank name, town, address and SWIFT code
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]Document
+	postings map[string]map[string]int // token -> swift code -> best field weight
+	terms    []string                  // sorted tokens, used for prefix scans
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     map[string]Document{},
+		postings: map[string]map[string]int{},
+	}
+}
+
+const documentQuery = `
+	SELECT swift_code, name, COALESCE(town_name, ''), address, branches.country_iso2, country_name, is_headquarter
+	FROM branches
+	INNER JOIN countries ON branches.country_iso2 = countries.country_iso2
+	WHERE branches.deleted_at IS NULL`
+
+// Read all branches currently stored in database
+func LoadDocuments(ctx context.Context, db *sql.DB) ([]Document, error) {
+	rows, err := db.QueryContext(ctx, documentQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		var doc Document
+		if err := rows.Scan(&doc.SWIFT_CODE, &doc.NAME, &doc.TOWN_NAME, &doc.ADDRESS,
+			&doc.COUNTRY_ISO2_CODEID, &doc.COUNTRY_NAME, &doc.IS_HEADQUARTER); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// Read a single branch, returns sql.ErrNoRows if it is not stored
+func LoadDocument(ctx context.Context, db *sql.DB, swift string) (Document, error) {
+	var doc Document
+	err := db.QueryRowContext(ctx, documentQuery+`
+	AND swift_code = ?`, swift).Scan(&doc.SWIFT_CODE, &doc.NAME, &doc.TOWN_NAME, &doc.ADDRESS,
+		&doc.COUNTRY_ISO2_CODEID, &doc.COUNTRY_NAME, &doc.IS_HEADQUARTER)
+	return doc, err
+}
+
+// Replace index contents with all branches currently stored in database
+func (idx *Index) Load(ctx context.Context, db *sql.DB) error {
+	docs, err := LoadDocuments(ctx, db)
+	if err != nil {
+		return err
+	}
+	idx.Reset(docs)
+	return nil
+}
+
+// Replace index contents with given documents
+func (idx *Index) Reset(docs []Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.docs = map[string]Document{}
+	idx.postings = map[string]map[string]int{}
+	for _, doc := range docs {
+		idx.add(doc)
+	}
+	idx.rebuildTerms()
+}
+
+// Add or replace a single document
+func (idx *Index) Add(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, token := range idx.remove(doc.SWIFT_CODE) {
+		idx.deleteTerm(token)
+	}
+	for _, token := range idx.add(doc) {
+		idx.insertTerm(token)
+	}
+}
+
+// Remove a document by its SWIFT code, no-op if it is not indexed
+func (idx *Index) Remove(swift string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, token := range idx.remove(swift) {
+		idx.deleteTerm(token)
+	}
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Search returns hits ordered by score, every query token has to match at least one field.
+// Country filter is optional, total is the number of hits before pagination.
+func (idx *Index) Search(query, country string, offset, limit int) ([]Hit, int) {
+	tokens := textnorm.Tokenize(query)
+	if len(tokens) == 0 {
+		return nil, 0
+	}
+	country = strings.ToUpper(country)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var scores map[string]float64
+	for _, token := range tokens {
+		tokenScores := idx.matchToken(token)
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		// Keep only documents matched by every token
+		for code, score := range scores {
+			if tokenScore, ok := tokenScores[code]; ok {
+				scores[code] = score + tokenScore
+			} else {
+				delete(scores, code)
+			}
+		}
+	}
+
+	var hits []Hit
+	for code, score := range scores {
+		doc := idx.docs[code]
+		if country != "" && doc.COUNTRY_ISO2_CODEID != country {
+			continue
+		}
+		hits = append(hits, Hit{doc, score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].SCORE != hits[j].SCORE {
+			return hits[i].SCORE > hits[j].SCORE
+		}
+		return hits[i].SWIFT_CODE < hits[j].SWIFT_CODE
+	})
+
+	total := len(hits)
+	if offset >= total {
+		return []Hit{}, total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return hits[offset:end], total
+}
+
+// Score every document matching a single query token, taking the best match kind per document
+func (idx *Index) matchToken(token string) map[string]float64 {
+	scores := map[string]float64{}
+	apply := func(term string, kind float64) {
+		for code, weight := range idx.postings[term] {
+			if score := float64(weight) * kind; score > scores[code] {
+				scores[code] = score
+			}
+		}
+	}
+
+	// Exact and prefix matches are a contiguous range in sorted terms
+	start := sort.SearchStrings(idx.terms, token)
+	for i := start; i < len(idx.terms) && strings.HasPrefix(idx.terms[i], token); i++ {
+		if idx.terms[i] == token {
+			apply(idx.terms[i], matchExact)
+		} else {
+			apply(idx.terms[i], matchPrefix)
+		}
+	}
+
+	// Short tokens are too ambiguous for fuzzy matching
+	maxDistance := 0
+	switch {
+	case len(token) >= 8:
+		maxDistance = 2
+	case len(token) >= 4:
+		maxDistance = 1
+	}
+	if maxDistance == 0 {
+		return scores
+	}
+	for _, term := range idx.terms {
+		if term == token || strings.HasPrefix(term, token) {
+			continue
+		}
+		if abs(len(term)-len(token)) > maxDistance {
+			continue
+		}
+		if levenshtein(term, token) <= maxDistance {
+			apply(term, matchFuzzy)
+		}
+	}
+	return scores
+}
+
+type weightedField struct {
+	text   string
+	weight int
+}
+
+func fields(doc Document) []weightedField {
+	return []weightedField{
+		{doc.SWIFT_CODE, fieldCode},
+		{doc.NAME, fieldName},
+		{doc.TOWN_NAME, fieldTown},
+		{doc.ADDRESS, fieldAddress},
+	}
+}
+
+// Index doc, returns tokens that were not indexed before
+func (idx *Index) add(doc Document) []string {
+	var added []string
+	idx.docs[doc.SWIFT_CODE] = doc
+	for _, field := range fields(doc) {
+		for _, token := range textnorm.Tokenize(field.text) {
+			codes, ok := idx.postings[token]
+			if !ok {
+				codes = map[string]int{}
+				idx.postings[token] = codes
+				added = append(added, token)
+			}
+			if field.weight > codes[doc.SWIFT_CODE] {
+				codes[doc.SWIFT_CODE] = field.weight
+			}
+		}
+	}
+	return added
+}
+
+// Drop a document, returns tokens no other document has
+func (idx *Index) remove(swift string) []string {
+	doc, ok := idx.docs[swift]
+	if !ok {
+		return nil
+	}
+	var removed []string
+	delete(idx.docs, swift)
+	for _, field := range fields(doc) {
+		for _, token := range textnorm.Tokenize(field.text) {
+			codes, ok := idx.postings[token]
+			if !ok {
+				continue
+			}
+			delete(codes, swift)
+			if len(codes) == 0 {
+				delete(idx.postings, token)
+				removed = append(removed, token)
+			}
+		}
+	}
+	return removed
+}
+
+// Keep terms sorted without re-sorting them on every write
+func (idx *Index) insertTerm(token string) {
+	i := sort.SearchStrings(idx.terms, token)
+	if i < len(idx.terms) && idx.terms[i] == token {
+		return
+	}
+	idx.terms = append(idx.terms, "")
+	copy(idx.terms[i+1:], idx.terms[i:])
+	idx.terms[i] = token
+}
+
+func (idx *Index) deleteTerm(token string) {
+	i := sort.SearchStrings(idx.terms, token)
+	if i < len(idx.terms) && idx.terms[i] == token {
+		idx.terms = append(idx.terms[:i], idx.terms[i+1:]...)
+	}
+}
+
+// Sort all tokens at once, used when the whole index is replaced
+func (idx *Index) rebuildTerms() {
+	terms := make([]string, 0, len(idx.postings))
+	for token := range idx.postings {
+		terms = append(terms, token)
+	}
+	sort.Strings(terms)
+	idx.terms = terms
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,117 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testIndex() *Index {
+	idx := NewIndex()
+	idx.Add(Document{SWIFT_CODE: "BREXPLPWXXX", NAME: "MBANK S.A.", TOWN_NAME: "WARSZAWA", ADDRESS: "PROSTA 18", COUNTRY_ISO2_CODEID: "PL", IS_HEADQUARTER: true})
+	idx.Add(Document{SWIFT_CODE: "BREXPLPWLOD", NAME: "MBANK S.A.", TOWN_NAME: "Łódź", ADDRESS: "PIOTRKOWSKA 1", COUNTRY_ISO2_CODEID: "PL"})
+	idx.Add(Document{SWIFT_CODE: "AIZKLV22XXX", NAME: "ABLV BANK, AS IN LIQUIDATION", TOWN_NAME: "RIGA", ADDRESS: "ELIZABETES IELA 23", COUNTRY_ISO2_CODEID: "LV", IS_HEADQUARTER: true})
+	return idx
+}
+
+func TestSearch(t *testing.T) {
+	idx := testIndex()
+
+	// Accent-insensitive match on town
+	hits, total := idx.Search("lodz", "", 0, 10)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "BREXPLPWLOD", hits[0].SWIFT_CODE)
+
+	// Prefix match, all tokens need to match
+	hits, total = idx.Search("mban warsz", "", 0, 10)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "BREXPLPWXXX", hits[0].SWIFT_CODE)
+
+	// Fuzzy match with a typo
+	_, total = idx.Search("liqidation", "", 0, 10)
+	assert.Equal(t, 1, total)
+
+	// Country filter
+	_, total = idx.Search("bank", "lv", 0, 10)
+	assert.Equal(t, 1, total)
+
+	// SWIFT code match ranks above address match
+	idx.Add(Document{SWIFT_CODE: "TESTPLP1XXX", NAME: "TEST BANK", ADDRESS: "BREXPLPWXXX STREET", COUNTRY_ISO2_CODEID: "PL"})
+	hits, total = idx.Search("brexplpwxxx", "", 0, 10)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "BREXPLPWXXX", hits[0].SWIFT_CODE)
+
+	// No matches
+	hits, total = idx.Search("nothing", "", 0, 10)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, hits)
+}
+
+func TestSearchPagination(t *testing.T) {
+	idx := testIndex()
+
+	hits, total := idx.Search("bank", "", 0, 2)
+	assert.Equal(t, 3, total)
+	assert.Len(t, hits, 2)
+
+	hits, _ = idx.Search("bank", "", 2, 2)
+	assert.Len(t, hits, 1)
+
+	hits, _ = idx.Search("bank", "", 10, 2)
+	assert.Empty(t, hits)
+}
+
+func TestRemove(t *testing.T) {
+	idx := testIndex()
+	idx.Remove("BREXPLPWLOD")
+
+	_, total := idx.Search("lodz", "", 0, 10)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 2, idx.Len())
+
+	// Replacing a document drops its old tokens
+	idx.Add(Document{SWIFT_CODE: "AIZKLV22XXX", NAME: "RENAMED", COUNTRY_ISO2_CODEID: "LV"})
+	_, total = idx.Search("ablv", "", 0, 10)
+	assert.Equal(t, 0, total)
+}
+
+func TestTermsStaySorted(t *testing.T) {
+	idx := testIndex()
+	idx.Remove("BREXPLPWLOD")
+	idx.Add(Document{SWIFT_CODE: "AIZKLV22XXX", NAME: "RENAMED", COUNTRY_ISO2_CODEID: "LV"})
+	idx.Add(Document{SWIFT_CODE: "BREXPLPWKRK", NAME: "MBANK S.A.", TOWN_NAME: "KRAKÓW", COUNTRY_ISO2_CODEID: "PL"})
+
+	// Terms updated one write at a time match a full rebuild
+	written := append([]string(nil), idx.terms...)
+	idx.rebuildTerms()
+	assert.Equal(t, idx.terms, written)
+	assert.NotContains(t, written, "lodz")
+	assert.Contains(t, written, "krakow")
+}
+
+func TestLoad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock database: %v", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"swift_code", "name", "town_name", "address", "country_iso2", "country_name", "is_headquarter"}).
+		AddRow("AAAJBG21XXX", "ARCUS ASSET MANAGEMENT JSC", "SOFIA", "SOFIA 1000", "BG", "BULGARIA", true)
+	mock.ExpectQuery("SELECT swift_code, name.*FROM branches").WillReturnRows(rows)
+
+	idx := testIndex()
+	err = idx.Load(context.Background(), db)
+	assert.NoError(t, err, "Load should not return an error")
+	assert.Equal(t, 1, idx.Len())
+
+	hits, _ := idx.Search("arcus", "", 0, 10)
+	assert.Len(t, hits, 1)
+	assert.Equal(t, "BULGARIA", hits[0].COUNTRY_NAME)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Mock expectations were not met: %v", err)
+	}
+}
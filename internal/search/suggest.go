@@ -0,0 +1,263 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/textnorm"
+)
+
+const (
+	SuggestionSwiftCode = "swiftCode"
+	SuggestionBankName  = "bankName"
+)
+
+type Suggestion struct {
+	TEXT       string `json:"text"`
+	TYPE       string `json:"type"`
+	SWIFT_CODE string `json:"swiftCode,omitempty"`
+	COUNT      int    `json:"count,omitempty"`
+}
+
+type trieNode struct {
+	keys     []rune // sorted, so walking the trie yields keys in lexical order
+	children map[rune]*trieNode
+	values   map[string]int // suggestion key -> reference count
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[rune]*trieNode{}}
+}
+
+// Prefix index over SWIFT codes and bank names, bank names can be matched from any word
+type Suggester struct {
+	mu    sync.RWMutex
+	root  *trieNode
+	codes map[string]string          // swift code -> bank name
+	names map[string]map[string]bool // bank name -> swift codes
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{
+		root:  newTrieNode(),
+		codes: map[string]string{},
+		names: map[string]map[string]bool{},
+	}
+}
+
+// Replace suggester contents with given documents
+func (s *Suggester) Reset(docs []Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.root = newTrieNode()
+	s.codes = map[string]string{}
+	s.names = map[string]map[string]bool{}
+	for _, doc := range docs {
+		s.add(doc.SWIFT_CODE, doc.NAME)
+	}
+}
+
+// Add or replace a single branch
+func (s *Suggester) Add(doc Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(doc.SWIFT_CODE)
+	s.add(doc.SWIFT_CODE, doc.NAME)
+}
+
+// Remove a branch by its SWIFT code, no-op if it is not indexed
+func (s *Suggester) Remove(swift string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(swift)
+}
+
+// Suggest returns up to limit codes and bank names starting with prefix,
+// codes are listed first when prefix looks like a SWIFT code
+func (s *Suggester) Suggest(prefix string, limit int) []Suggestion {
+	key := strings.Join(textnorm.Tokenize(prefix), " ")
+	if key == "" || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	node := s.root
+	for _, r := range key {
+		node = node.children[r]
+		if node == nil {
+			return []Suggestion{}
+		}
+	}
+
+	var codes, names []Suggestion
+	seen := map[string]bool{}
+	node.walk(func(value string) bool {
+		if seen[value] {
+			return true
+		}
+		seen[value] = true
+		if swift, ok := strings.CutPrefix(value, SuggestionSwiftCode+":"); ok {
+			if len(codes) < limit {
+				codes = append(codes, Suggestion{TEXT: swift, TYPE: SuggestionSwiftCode, SWIFT_CODE: swift})
+			}
+		} else if name, ok := strings.CutPrefix(value, SuggestionBankName+":"); ok {
+			suggestion := Suggestion{TEXT: name, TYPE: SuggestionBankName, COUNT: len(s.names[name])}
+			// A name with a single branch can point straight at its code
+			if suggestion.COUNT == 1 {
+				for swift := range s.names[name] {
+					suggestion.SWIFT_CODE = swift
+				}
+			}
+			names = append(names, suggestion)
+		}
+		// All matching names are needed to rank them
+		return true
+	})
+
+	// Bank names with the most branches are the likeliest picks
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].COUNT != names[j].COUNT {
+			return names[i].COUNT > names[j].COUNT
+		}
+		return names[i].TEXT < names[j].TEXT
+	})
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	var suggestions []Suggestion
+	if looksLikeCode(key) {
+		suggestions = append(codes, names...)
+	} else {
+		suggestions = append(names, codes...)
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// Walk all values below node in lexical order of their keys until visit returns false
+func (n *trieNode) walk(visit func(string) bool) bool {
+	if len(n.values) > 0 {
+		values := make([]string, 0, len(n.values))
+		for value := range n.values {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			if !visit(value) {
+				return false
+			}
+		}
+	}
+	for _, r := range n.keys {
+		if !n.children[r].walk(visit) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Suggester) add(swift, name string) {
+	s.codes[swift] = name
+	s.insert(textnorm.Fold(swift), SuggestionSwiftCode+":"+swift)
+
+	codes, ok := s.names[name]
+	if !ok {
+		codes = map[string]bool{}
+		s.names[name] = codes
+	}
+	codes[swift] = true
+	if len(codes) > 1 {
+		return
+	}
+	for _, key := range nameKeys(name) {
+		s.insert(key, SuggestionBankName+":"+name)
+	}
+}
+
+func (s *Suggester) remove(swift string) {
+	name, ok := s.codes[swift]
+	if !ok {
+		return
+	}
+	delete(s.codes, swift)
+	s.delete(textnorm.Fold(swift), SuggestionSwiftCode+":"+swift)
+
+	delete(s.names[name], swift)
+	if len(s.names[name]) > 0 {
+		return
+	}
+	delete(s.names, name)
+	for _, key := range nameKeys(name) {
+		s.delete(key, SuggestionBankName+":"+name)
+	}
+}
+
+func (s *Suggester) insert(key, value string) {
+	node := s.root
+	for _, r := range key {
+		child, ok := node.children[r]
+		if !ok {
+			child = newTrieNode()
+			node.children[r] = child
+			i := sort.Search(len(node.keys), func(i int) bool { return node.keys[i] >= r })
+			node.keys = append(node.keys, 0)
+			copy(node.keys[i+1:], node.keys[i:])
+			node.keys[i] = r
+		}
+		node = child
+	}
+	if node.values == nil {
+		node.values = map[string]int{}
+	}
+	node.values[value]++
+}
+
+func (s *Suggester) delete(key, value string) {
+	// Remember the path so empty nodes can be pruned bottom up
+	path := []*trieNode{s.root}
+	node := s.root
+	for _, r := range key {
+		node = node.children[r]
+		if node == nil {
+			return
+		}
+		path = append(path, node)
+	}
+	node.values[value]--
+	if node.values[value] <= 0 {
+		delete(node.values, value)
+	}
+
+	runes := []rune(key)
+	for i := len(path) - 1; i > 0; i-- {
+		child := path[i]
+		if len(child.values) > 0 || len(child.children) > 0 {
+			break
+		}
+		parent, r := path[i-1], runes[i-1]
+		delete(parent.children, r)
+		j := sort.Search(len(parent.keys), func(j int) bool { return parent.keys[j] >= r })
+		parent.keys = append(parent.keys[:j], parent.keys[j+1:]...)
+	}
+}
+
+// Bank name is indexed from every word, so "bank" suggests "ABLV BANK, AS IN LIQUIDATION"
+func nameKeys(name string) []string {
+	tokens := textnorm.Tokenize(name)
+	keys := make([]string, 0, len(tokens))
+	for i := range tokens {
+		keys = append(keys, strings.Join(tokens[i:], " "))
+	}
+	return keys
+}
+
+// Anything past the 4 letter bank code without spaces is more likely a code than a name
+func looksLikeCode(key string) bool {
+	return len(key) >= 4 && len(key) <= 11 && !strings.Contains(key, " ")
+}
@@ -0,0 +1,84 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSuggester() *Suggester {
+	s := NewSuggester()
+	s.Reset([]Document{
+		{SWIFT_CODE: "AIZKLV22XXX", NAME: "ABLV BANK, AS IN LIQUIDATION"},
+		{SWIFT_CODE: "AIZKLV22CLN", NAME: "ABLV BANK, AS IN LIQUIDATION"},
+		{SWIFT_CODE: "BREXPLPWXXX", NAME: "MBANK S.A."},
+		{SWIFT_CODE: "ABIEBGS1XXX", NAME: "ABV INVESTMENTS LTD"},
+	})
+	return s
+}
+
+func TestSuggestCodes(t *testing.T) {
+	s := testSuggester()
+
+	suggestions := s.Suggest("aizk", 10)
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, "AIZKLV22CLN", suggestions[0].TEXT)
+	assert.Equal(t, "AIZKLV22XXX", suggestions[1].TEXT)
+	assert.Equal(t, SuggestionSwiftCode, suggestions[1].TYPE)
+
+	// Codes go before names for code-like prefixes
+	s.Add(Document{SWIFT_CODE: "ABVTBGS1XXX", NAME: "ABVT HOLDING"})
+	suggestions = s.Suggest("abvt", 10)
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, SuggestionSwiftCode, suggestions[0].TYPE)
+	assert.Equal(t, SuggestionBankName, suggestions[1].TYPE)
+
+	assert.Len(t, s.Suggest("aizk", 1), 1)
+	assert.Empty(t, s.Suggest("zzzz", 10))
+	assert.Empty(t, s.Suggest("", 10))
+}
+
+func TestSuggestNames(t *testing.T) {
+	s := testSuggester()
+
+	// Names match from any word and ignore punctuation
+	suggestions := s.Suggest("bank as", 10)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, "ABLV BANK, AS IN LIQUIDATION", suggestions[0].TEXT)
+	assert.Equal(t, 2, suggestions[0].COUNT)
+
+	// Name with a single branch carries its code
+	suggestions = s.Suggest("mbank", 10)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, "BREXPLPWXXX", suggestions[0].SWIFT_CODE)
+
+	// Names first for short prefixes, larger banks first
+	suggestions = s.Suggest("ab", 10)
+	assert.Equal(t, "ABLV BANK, AS IN LIQUIDATION", suggestions[0].TEXT)
+	assert.Equal(t, "ABV INVESTMENTS LTD", suggestions[1].TEXT)
+
+	// Limit keeps the largest banks, not the first names in alphabetical order
+	s.Add(Document{SWIFT_CODE: "ABAABGS1XXX", NAME: "AB AAA BANK"})
+	suggestions = s.Suggest("ab", 1)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, "ABLV BANK, AS IN LIQUIDATION", suggestions[0].TEXT)
+}
+
+func TestSuggestRemove(t *testing.T) {
+	s := testSuggester()
+
+	s.Remove("AIZKLV22CLN")
+	suggestions := s.Suggest("ablv", 10)
+	assert.Len(t, suggestions, 1)
+	assert.Equal(t, 1, suggestions[0].COUNT)
+	assert.Equal(t, "AIZKLV22XXX", suggestions[0].SWIFT_CODE)
+
+	s.Remove("AIZKLV22XXX")
+	assert.Empty(t, s.Suggest("ablv", 10))
+	assert.Empty(t, s.Suggest("aizk", 10))
+
+	// Renaming a branch replaces its name
+	s.Add(Document{SWIFT_CODE: "BREXPLPWXXX", NAME: "BRE BANK"})
+	assert.Empty(t, s.Suggest("mbank", 10))
+	assert.Len(t, s.Suggest("bre bank", 10), 1)
+}
@@ -0,0 +1,13 @@
+package textnorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFold(t *testing.T) {
+	assert.Equal(t, "lodz", Fold("Łódź"))
+	assert.Equal(t, "sao paulo", Fold("SÃO PAULO"))
+	assert.Equal(t, []string{"ablv", "bank", "as", "in", "liquidation"}, Tokenize("ABLV BANK, AS IN LIQUIDATION"))
+}
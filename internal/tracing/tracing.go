@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"Michal_Gomulczak_Assessment/SWIFT-API/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Instrumentation name of the server's own spans
+const tracerName = "Michal_Gomulczak_Assessment/SWIFT-API"
+
+// Install the global tracer provider for conf, returns a function that flushes and stops it.
+// Incoming traceparent headers are continued with any exporter, none only skips recording spans
+func Setup(ctx context.Context, conf config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch conf.EXPORTER {
+	case config.ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(conf.ENDPOINT))
+	case config.ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(conf.SERVICE_NAME)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SAMPLE_RATIO))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start a span of the server's own work, like imports and purges
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name)
+}
+
+type untracedKey struct{}
+
+// Context whose storage calls are not traced, for work with too many calls to show one by one.
+// Its span is kept, so log lines of the work still carry the trace ID
+func Untraced(ctx context.Context) context.Context {
+	return context.WithValue(ctx, untracedKey{}, true)
+}
+
+// Whether storage calls under ctx were left out of the trace with Untraced
+func IsUntraced(ctx context.Context) bool {
+	untraced, _ := ctx.Value(untracedKey{}).(bool)
+	return untraced
+}
+
+// End span, marking it failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}